@@ -0,0 +1,166 @@
+package databuilder
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// WithAlternative marks the builder as an alternative producer of its outputs, any number of alternatives can be
+// added for the same output and Compile keeps a single one of them. The alternative kept is the one with the highest
+// priority among the alternatives whose inputs can be built from the initial data, compiling fails with
+// ErrAmbiguousDependency when more than one of them has that priority
+//
+//	b.AddBuilders(
+//		Configure(ProfileFromUserID, WithAlternative(1)),
+//		Configure(ProfileFromSession, WithAlternative(0)),
+//	)
+//	fromID, err := b.Compile(UserID(""))            // ProfileFromUserID builds UserProfile
+//	fromSession, err := b.Compile(SessionToken("")) // ProfileFromSession builds UserProfile
+func WithAlternative(priority int) BuilderOption {
+	return func(b *builder) error {
+		b.alternative = true
+		b.priority = priority
+		return nil
+	}
+}
+
+// isAlternative checks if every builder producing out is an alternative
+func (d *db) isAlternative(out string) bool {
+	for _, b := range d.builders {
+		if slices.Contains(b.Out, out) && !b.alternative {
+			return false
+		}
+	}
+	return true
+}
+
+// chooseAlternatives keeps one builder for every output produced by more than one alternative, preferring the
+// alternatives whose inputs can be built from initData. It returns the builders kept along with the outputs for
+// which no single alternative could be chosen, mapped to the error describing it
+func chooseAlternatives(builders map[string]*builder, initData []string) (map[string]*builder, map[string]error) {
+	producers := make(map[string][]*builder)
+	for _, b := range builders {
+		for _, out := range b.Out {
+			if b.alternative {
+				producers[out] = append(producers[out], b)
+			}
+		}
+	}
+	outs := make([]string, 0, len(producers))
+	for out, bs := range producers {
+		if len(bs) > 1 {
+			outs = append(outs, out)
+		}
+	}
+	if len(outs) == 0 {
+		return builders, nil
+	}
+	slices.Sort(outs)
+
+	available := satisfiable(builders, initData)
+	kept := make(map[*builder]bool)
+	dropped := make(map[*builder]bool)
+	ambiguous := make(map[string]error)
+	for _, out := range outs {
+		candidates := slices.DeleteFunc(slices.Clone(producers[out]), func(b *builder) bool { return dropped[b] })
+		if len(candidates) < 2 {
+			continue
+		}
+		chosen := slices.DeleteFunc(slices.Clone(candidates), func(b *builder) bool { return !kept[b] })
+		if len(chosen) == 0 {
+			chosen = bestAlternatives(candidates, available)
+		}
+		if len(chosen) > 1 {
+			names := make([]string, 0, len(chosen))
+			for _, b := range chosen {
+				names = append(names, b.Name)
+			}
+			slices.Sort(names)
+			ambiguous[out] = fmt.Errorf("%w: %s can be built by %s", ErrAmbiguousDependency, out, strings.Join(names, ", "))
+			continue
+		}
+		kept[chosen[0]] = true
+		for _, b := range candidates {
+			if b != chosen[0] {
+				dropped[b] = true
+			}
+		}
+	}
+
+	result := make(map[string]*builder, len(builders))
+	for name, b := range builders {
+		if !dropped[b] {
+			result[name] = b
+		}
+	}
+	for out := range ambiguous {
+		// none of the alternatives is kept, the output is only reported when it is needed
+		for _, b := range producers[out] {
+			if !kept[b] {
+				delete(result, b.Name)
+			}
+		}
+	}
+	return result, ambiguous
+}
+
+// bestAlternatives returns the candidates with the highest priority among the candidates whose inputs are available.
+// Without available candidates the one with the highest priority and the lowest name is returned so resolving
+// dependencies reports what it is missing
+func bestAlternatives(candidates []*builder, available stringSet) []*builder {
+	pool := slices.DeleteFunc(slices.Clone(candidates), func(b *builder) bool {
+		return !available.IsSuperset(newStringSet(b.In...))
+	})
+	if len(pool) == 0 {
+		return []*builder{slices.MinFunc(candidates, func(a, b *builder) int {
+			if a.priority != b.priority {
+				return b.priority - a.priority
+			}
+			return strings.Compare(a.Name, b.Name)
+		})}
+	}
+	best := slices.MaxFunc(pool, func(a, b *builder) int { return a.priority - b.priority }).priority
+	return slices.DeleteFunc(pool, func(b *builder) bool { return b.priority != best })
+}
+
+// satisfiable returns the data that can be built from initData with the builders
+func satisfiable(builders map[string]*builder, initData []string) stringSet {
+	available := newStringSet(initData...)
+	for progress := true; progress; {
+		progress = false
+		for _, b := range builders {
+			if available.IsSuperset(newStringSet(b.Out...)) || !available.IsSuperset(newStringSet(b.In...)) {
+				continue
+			}
+			available.Insert(b.Out...)
+			progress = true
+		}
+	}
+	return available
+}
+
+// ambiguityError returns the errors of the ambiguous outputs that are needed to build targets with the builders,
+// every ambiguous output is needed when targets is nil
+func ambiguityError(builders map[string]*builder, targets []string, ambiguous map[string]error) error {
+	if len(ambiguous) == 0 {
+		return nil
+	}
+	needed := newStringSet(targets...)
+	for _, b := range builders {
+		needed.Insert(b.In...)
+		needed.Insert(b.Optional...)
+	}
+	outs := make([]string, 0, len(ambiguous))
+	for out := range ambiguous {
+		if targets == nil || needed.Has(out) {
+			outs = append(outs, out)
+		}
+	}
+	slices.Sort(outs)
+	errs := make([]error, 0, len(outs))
+	for _, out := range outs {
+		errs = append(errs, ambiguous[out])
+	}
+	return joinErrors(errs)
+}
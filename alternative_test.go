@@ -0,0 +1,81 @@
+package databuilder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlternatives(t *testing.T) {
+	fromID := func(s TestStruct1) TestStruct3 {
+		return TestStruct3{Value: "id:" + s.Value}
+	}
+	fromSession := func(s TestStruct2) TestStruct3 {
+		return TestStruct3{Value: "session:" + s.Value}
+	}
+	sessionFromToken := func(s TestStruct5) TestStruct2 {
+		return TestStruct2{Value: s.Value}
+	}
+	profile := func(s TestStruct3) TestStruct4 {
+		return TestStruct4{Value: s.Value}
+	}
+	d := testNew(t)
+	err := d.AddBuilders(
+		Configure(fromID, WithName("fromID"), WithAlternative(1)),
+		Configure(fromSession, WithName("fromSession"), WithAlternative(0)),
+		sessionFromToken,
+		profile,
+	)
+	assert.NoError(t, err, "alternatives can share their outputs")
+
+	executionPlan, err := d.CompileFor([]any{TestStruct4{}}, TestStruct1{})
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "id:1", MustGet[TestStruct4](result).Value)
+
+	executionPlan, err = d.CompileFor([]any{TestStruct4{}}, TestStruct5{})
+	assert.NoError(t, err, "inputs of alternatives can be built by other builders")
+	result, err = executionPlan.Run(context.Background(), TestStruct5{Value: "token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "session:token", MustGet[TestStruct4](result).Value)
+
+	executionPlan, err = d.CompileFor([]any{TestStruct4{}}, TestStruct1{}, TestStruct2{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "1"}, TestStruct2{Value: "s"})
+	assert.NoError(t, err)
+	assert.Equal(t, "id:1", MustGet[TestStruct4](result).Value, "the alternative with the highest priority should be chosen")
+
+	_, err = d.CompileFor([]any{TestStruct4{}})
+	assert.ErrorIs(t, err, ErrCouldNotResolveDependency, "missing inputs should be reported when no alternative can be built")
+}
+
+func TestAlternativesAmbiguous(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(
+		Configure(func(TestStruct1) TestStruct3 { return TestStruct3{} }, WithName("a"), WithAlternative(0)),
+		Configure(func(TestStruct2) TestStruct3 { return TestStruct3{} }, WithName("b"), WithAlternative(0)),
+		func(TestStruct3) TestStruct4 { return TestStruct4{} },
+		func(TestStruct1) TestStruct5 { return TestStruct5{} },
+	)
+	assert.NoError(t, err)
+
+	_, err = d.Compile(TestStruct1{}, TestStruct2{})
+	assert.ErrorIs(t, err, ErrAmbiguousDependency)
+	assert.ErrorContains(t, err, "a, b")
+	_, err = d.CompileFor([]any{TestStruct4{}}, TestStruct1{}, TestStruct2{})
+	assert.ErrorIs(t, err, ErrAmbiguousDependency)
+	_, err = d.CompileFor([]any{TestStruct3{}}, TestStruct1{}, TestStruct2{})
+	assert.ErrorIs(t, err, ErrAmbiguousDependency)
+	_, err = d.CompileFor([]any{TestStruct5{}}, TestStruct1{}, TestStruct2{})
+	assert.NoError(t, err, "ambiguous outputs that are not needed should be ignored")
+	_, err = d.CompileFor([]any{TestStruct4{}}, TestStruct2{})
+	assert.NoError(t, err, "only one alternative can be built")
+
+	err = d.AddBuilders(func(TestStruct5) TestStruct3 { return TestStruct3{} })
+	assert.ErrorIs(t, err, ErrMultipleBuilderSameOutput, "builders that are not alternatives can not share outputs")
+	d = testNew(t)
+	err = d.AddBuilders(DBTestFunc, Configure(DBTestFunc5, WithAlternative(1)))
+	assert.ErrorIs(t, err, ErrMultipleBuilderSameOutput, "every producer of the output should be an alternative")
+}
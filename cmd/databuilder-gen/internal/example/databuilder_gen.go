@@ -0,0 +1,62 @@
+// Code generated by databuilder-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"context"
+
+	databuilder "github.com/go-coldbrew/data-builder"
+)
+
+// NewAppPlan creates the plan of the builders FetchUser, FetchPreferences, Greet, BuildResponse without reflection
+func NewAppPlan(opts ...databuilder.Option) (databuilder.Plan, error) {
+	return databuilder.NewPlan([]databuilder.Step{
+		{
+			Func: FetchUser,
+			Inputs: []databuilder.StepInput{
+				{Name: "github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.AppRequest"},
+			},
+			Out: []string{"github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.User", "github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.Account"},
+			Call: func(ctx context.Context, args []any) ([]any, error) {
+				o0, o1, err := FetchUser(ctx, args[0].(AppRequest))
+				return []any{o0, o1}, err
+			},
+		},
+		{
+			Func: FetchPreferences,
+			Inputs: []databuilder.StepInput{
+				{Name: "github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.User"},
+			},
+			Out: []string{"*github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.Preferences"},
+			Call: func(ctx context.Context, args []any) ([]any, error) {
+				o0, err := FetchPreferences(ctx, args[0].(User))
+				return []any{o0}, err
+			},
+		},
+		{
+			Func: Greet,
+			Inputs: []databuilder.StepInput{
+				{Name: "github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.User"},
+			},
+			Out: []string{"github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.Greeting"},
+			Call: func(ctx context.Context, args []any) ([]any, error) {
+				o0 := Greet(args[0].(User))
+				return []any{o0}, nil
+			},
+		},
+		{
+			Func: BuildResponse,
+			Inputs: []databuilder.StepInput{
+				{Name: "github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.Greeting"},
+				{Name: "github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.Account"},
+				{Name: "*github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.Preferences", Optional: true},
+			},
+			Out: []string{"*github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.AppResponse"},
+			Call: func(ctx context.Context, args []any) ([]any, error) {
+				v2, ok2 := args[2].(*Preferences)
+				o0, err := BuildResponse(ctx, args[0].(Greeting), args[1].(Account), databuilder.Optional[*Preferences]{Value: v2, Valid: ok2})
+				return []any{o0}, err
+			},
+		},
+	}, []string{"github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.AppRequest"}, []string{"*github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.AppResponse"}, opts...)
+}
@@ -0,0 +1,96 @@
+// Package example holds builders used to test the code generated by databuilder-gen
+package example
+
+//go:generate go run github.com/go-coldbrew/data-builder/cmd/databuilder-gen -builders FetchUser,FetchPreferences,Greet,BuildResponse -init AppRequest -targets *AppResponse -func NewAppPlan
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	databuilder "github.com/go-coldbrew/data-builder"
+)
+
+type AppRequest struct {
+	UserID string
+	At     time.Time
+}
+
+type User struct {
+	Name string
+}
+
+type Account struct {
+	Plan string
+}
+
+type Preferences struct {
+	Theme string
+}
+
+type Greeting string
+
+type AppResponse struct {
+	Message string
+}
+
+// ErrUnknownUser is returned by FetchUser for requests without a user
+var ErrUnknownUser = errors.New("unknown user")
+
+func FetchUser(_ context.Context, req AppRequest) (User, Account, error) {
+	if req.UserID == "" {
+		return User{}, Account{}, ErrUnknownUser
+	}
+	return User{Name: "user-" + req.UserID}, Account{Plan: "free"}, nil
+}
+
+func FetchPreferences(_ context.Context, u User) (*Preferences, error) {
+	if u.Name == "user-panic" {
+		panic("no preferences")
+	}
+	return &Preferences{Theme: "dark"}, nil
+}
+
+func Greet(u User) Greeting {
+	return Greeting(u.Name)
+}
+
+func BuildResponse(_ context.Context, g Greeting, a Account, p databuilder.Optional[*Preferences]) (*AppResponse, error) {
+	theme := "light"
+	if p.Valid {
+		theme = p.Value.Theme
+	}
+	return &AppResponse{Message: string(g) + " " + a.Plan + " " + theme}, nil
+}
+
+// ValidationError is returned by ValidateUser
+type ValidationError struct {
+	Field string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid " + e.Field
+}
+
+// ValidateUser is a valid builder that databuilder-gen does not support, its error is not of type error
+func ValidateUser(u User) (Account, *ValidationError) {
+	if u.Name == "" {
+		return Account{}, &ValidationError{Field: "name"}
+	}
+	return Account{Plan: "free"}, nil
+}
+
+// Named is implemented by data with a name
+type Named interface {
+	GetName() string
+}
+
+// GetName returns the name of the user
+func (u User) GetName() string {
+	return u.Name
+}
+
+// GreetNamed is a valid builder that databuilder-gen does not support, its input is an interface
+func GreetNamed(n Named) Greeting {
+	return Greeting(n.GetName())
+}
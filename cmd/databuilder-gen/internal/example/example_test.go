@@ -0,0 +1,36 @@
+package example
+
+import (
+	"context"
+	"testing"
+
+	databuilder "github.com/go-coldbrew/data-builder"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestGeneratedPlan(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	generated, err := NewAppPlan()
+	assert.NoError(t, err)
+	d := databuilder.New()
+	err = d.AddBuilders(FetchUser, FetchPreferences, Greet, BuildResponse)
+	assert.NoError(t, err)
+	reflected, err := d.CompileFor([]any{&AppResponse{}}, AppRequest{})
+	assert.NoError(t, err)
+
+	for _, userID := range []string{"1", "", "panic"} {
+		req := AppRequest{UserID: userID}
+		want, wantErr := reflected.RunParallel(context.Background(), 2, req)
+		got, err := generated.RunParallel(context.Background(), 2, req)
+		assert.Equal(t, want, got, "generated plan should build the same result for user %q", userID)
+		if wantErr == nil {
+			assert.NoError(t, err)
+			continue
+		}
+		assert.EqualError(t, err, wantErr.Error(), "generated plan should fail the same way for user %q", userID)
+	}
+	got, err := generated.Run(context.Background(), AppRequest{UserID: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1 free dark", databuilder.MustGet[*AppResponse](got).Message)
+}
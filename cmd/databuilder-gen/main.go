@@ -0,0 +1,444 @@
+// Command databuilder-gen generates a plan for a set of builder functions that runs without reflection.
+//
+// The generated function creates the plan with databuilder.NewPlan, the builders are resolved, scheduled,
+// traced and reported the same way as builders added with DataBuilder.AddBuilders, only the calls to the
+// builders and the names data is identified with are generated instead of being derived with reflection.
+// Use it with go generate in the package that declares the builders
+//
+//	//go:generate go run github.com/go-coldbrew/data-builder/cmd/databuilder-gen -builders FetchUser,BuildResponse -init AppRequest -targets AppResponse -func NewAppPlan
+//
+// the last result of a builder should be error itself when the builder can fail, other types implementing error
+// are not supported. Interface inputs are not supported either, the generated plan does not bind them to the data
+// implementing them, builders should depend on the data itself.
+//
+// types given to -init and -targets are spelled the way they are written in the package, e.g. AppRequest,
+// *AppRequest or pb.GetUserResponse
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const databuilderPath = "github.com/go-coldbrew/data-builder"
+
+type config struct {
+	dir      string   // directory of the package that declares the builders
+	builders []string // names of the builder functions
+	init     []string // types the plan is run with
+	targets  []string // types the plan is compiled for, all builders are part of the plan when empty
+	funcName string   // name of the generated function
+}
+
+func main() {
+	var cfg config
+	var builders, init, targets, output string
+	flag.StringVar(&builders, "builders", "", "comma separated names of the builder functions")
+	flag.StringVar(&init, "init", "", "comma separated types the plan is run with")
+	flag.StringVar(&targets, "targets", "", "comma separated types the plan is compiled for, all builders are part of the plan when empty")
+	flag.StringVar(&cfg.funcName, "func", "NewPlan", "name of the generated function")
+	flag.StringVar(&output, "o", "databuilder_gen.go", "name of the generated file")
+	flag.Parse()
+
+	cfg.dir = "."
+	if flag.NArg() > 0 {
+		cfg.dir = flag.Arg(0)
+	}
+	cfg.builders = splitList(builders)
+	cfg.init = splitList(init)
+	cfg.targets = splitList(targets)
+
+	pkg, err := loadPackage(cfg.dir, output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "databuilder-gen:", err)
+		os.Exit(1)
+	}
+	src, err := generate(pkg, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "databuilder-gen:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.dir, output), src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "databuilder-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// step is a builder function as it is written to the generated code
+type step struct {
+	name        string
+	withContext bool // the first parameter is context.Context
+	withError   bool // the last result is error
+	params      []types.Type
+	optional    []bool
+	inputs      []string // names of the data inputs
+	results     []types.Type
+	outputs     []string // names of the data outputs
+}
+
+// loadPackage loads the package in dir, the previously generated file named output is left out
+// as it might not be valid anymore
+func loadPackage(dir, output string) (*types.Package, error) {
+	overlay := make(map[string][]byte)
+	path, err := filepath.Abs(filepath.Join(dir, output))
+	if err != nil {
+		return nil, err
+	}
+	if f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly); err == nil {
+		overlay[path] = []byte("package " + f.Name.Name + "\n")
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:    packages.NeedName | packages.NeedTypes,
+		Dir:     dir,
+		Overlay: overlay,
+	}, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+	if len(pkgs[0].Errors) > 0 {
+		return nil, pkgs[0].Errors[0]
+	}
+	return pkgs[0].Types, nil
+}
+
+// generate returns the generated source for the builders of pkg
+func generate(pkg *types.Package, cfg config) ([]byte, error) {
+	if len(cfg.builders) == 0 {
+		return nil, errors.New("no builders given")
+	}
+	if cfg.funcName == "" {
+		return nil, errors.New("no function name given")
+	}
+
+	im := newImports(pkg)
+	steps := make([]step, 0, len(cfg.builders))
+	known := make(map[string]string) // mapping between the spelling of data types and their names
+	for _, name := range cfg.builders {
+		s, err := newStep(pkg, name)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range s.params {
+			t := p
+			if s.optional[i] {
+				t = optionalOf(p)
+			}
+			known[types.TypeString(t, types.RelativeTo(pkg))] = s.inputs[i]
+		}
+		for i, r := range s.results {
+			known[types.TypeString(r, types.RelativeTo(pkg))] = s.outputs[i]
+		}
+		steps = append(steps, s)
+	}
+	initData, err := lookupData(known, cfg.init)
+	if err != nil {
+		return nil, err
+	}
+	targetData, err := lookupData(known, cfg.targets)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "// %s creates the plan of the builders %s without reflection\n", cfg.funcName, strings.Join(cfg.builders, ", "))
+	fmt.Fprintf(&body, "func %s(opts ...databuilder.Option) (databuilder.Plan, error) {\n", cfg.funcName)
+	body.WriteString("return databuilder.NewPlan([]databuilder.Step{\n")
+	for _, s := range steps {
+		writeStep(&body, s, im)
+	}
+	fmt.Fprintf(&body, "}, %s, %s, opts...)\n}\n", stringSlice(initData), stringSlice(targetData))
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by databuilder-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg.Name())
+	src.WriteString("import (\n")
+	std := true
+	for _, path := range im.paths() {
+		if std && !isStd(path) {
+			// standard library imports come first
+			std = false
+			src.WriteString("\n")
+		}
+		if im.names[path] == filepath.Base(path) {
+			fmt.Fprintf(&src, "%q\n", path)
+		} else {
+			fmt.Fprintf(&src, "%s %q\n", im.names[path], path)
+		}
+	}
+	src.WriteString(")\n\n")
+	src.Write(body.Bytes())
+	return format.Source(src.Bytes())
+}
+
+func writeStep(w *bytes.Buffer, s step, im *imports) {
+	w.WriteString("{\n")
+	fmt.Fprintf(w, "Func: %s,\n", s.name)
+	if len(s.inputs) > 0 {
+		w.WriteString("Inputs: []databuilder.StepInput{\n")
+		for i, in := range s.inputs {
+			if s.optional[i] {
+				fmt.Fprintf(w, "{Name: %q, Optional: true},\n", in)
+			} else {
+				fmt.Fprintf(w, "{Name: %q},\n", in)
+			}
+		}
+		w.WriteString("},\n")
+	}
+	fmt.Fprintf(w, "Out: %s,\n", stringSlice(s.outputs))
+	fmt.Fprintf(w, "Call: func(ctx %s.Context, args []any) ([]any, error) {\n", im.name("context"))
+	var args []string
+	if s.withContext {
+		args = append(args, "ctx")
+	}
+	for i, p := range s.params {
+		if s.optional[i] {
+			// absent optional inputs are not of the type of the input
+			fmt.Fprintf(w, "v%d, ok%d := args[%d].(%s)\n", i, i, i, im.typeString(optionalOf(p)))
+			args = append(args, fmt.Sprintf("%s{Value: v%d, Valid: ok%d}", im.typeString(p), i, i))
+			continue
+		}
+		args = append(args, fmt.Sprintf("args[%d].(%s)", i, im.typeString(p)))
+	}
+	outs := make([]string, 0, len(s.results))
+	for i := range s.results {
+		outs = append(outs, "o"+strconv.Itoa(i))
+	}
+	if s.withError {
+		fmt.Fprintf(w, "%s, err := %s(%s)\n", strings.Join(outs, ", "), s.name, strings.Join(args, ", "))
+		fmt.Fprintf(w, "return []any{%s}, err\n", strings.Join(outs, ", "))
+	} else {
+		fmt.Fprintf(w, "%s := %s(%s)\n", strings.Join(outs, ", "), s.name, strings.Join(args, ", "))
+		fmt.Fprintf(w, "return []any{%s}, nil\n", strings.Join(outs, ", "))
+	}
+	w.WriteString("},\n},\n")
+}
+
+func stringSlice(items []string) string {
+	if len(items) == 0 {
+		return "nil"
+	}
+	quoted := make([]string, 0, len(items))
+	for _, item := range items {
+		quoted = append(quoted, strconv.Quote(item))
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
+
+// lookupData returns the names of the data types spelled in list
+func lookupData(known map[string]string, list []string) ([]string, error) {
+	var names []string
+	for _, spelling := range list {
+		name, ok := known[spelling]
+		if !ok {
+			return nil, fmt.Errorf("type %s is not an input or output of the builders", spelling)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// newStep checks that the function name in pkg is a valid builder, the same way databuilder.IsValidBuilder does
+func newStep(pkg *types.Package, name string) (step, error) {
+	s := step{name: name}
+	obj, ok := pkg.Scope().Lookup(name).(*types.Func)
+	if !ok {
+		return s, fmt.Errorf("%s is not a function of package %s", name, pkg.Path())
+	}
+	sig := obj.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 || sig.Variadic() {
+		return s, fmt.Errorf("builder %s: generic and variadic functions are not supported", name)
+	}
+	// context.Context and error are optional
+	s.withContext = sig.Params().Len() > 0 && isContext(sig.Params().At(0).Type())
+	errorType := types.Universe.Lookup("error").Type()
+	s.withError = sig.Results().Len() > 0 && types.Identical(sig.Results().At(sig.Results().Len()-1).Type(), errorType)
+	last, first := sig.Results().Len(), 0
+	if !s.withError && last > 0 && types.Implements(sig.Results().At(last-1).Type(), errorType.Underlying().(*types.Interface)) {
+		return s, fmt.Errorf("builder %s: return error instead of %s", name, sig.Results().At(last-1).Type())
+	}
+	if s.withError {
+		last--
+	}
+	if s.withContext {
+		first++
+	}
+	if last == 0 {
+		return s, fmt.Errorf("builder %s: should return data", name)
+	}
+	for i := 0; i < last; i++ {
+		t := sig.Results().At(i).Type()
+		if !isData(t) {
+			return s, fmt.Errorf("builder %s: output %s should be a named type or a pointer to a named type", name, t)
+		}
+		s.results = append(s.results, t)
+		s.outputs = append(s.outputs, dataName(t))
+	}
+	for i := first; i < sig.Params().Len(); i++ {
+		t := sig.Params().At(i).Type()
+		data, optional := t, false
+		if o := optionalOf(t); o != nil {
+			data, optional = o, true
+		}
+		if types.IsInterface(data) {
+			return s, fmt.Errorf("builder %s: interface inputs like %s are not supported", name, t)
+		}
+		if !isData(data) {
+			return s, fmt.Errorf("builder %s: input %s should be a named type or a pointer to a named type", name, t)
+		}
+		if isInputs(data) {
+			return s, fmt.Errorf("builder %s: inputs structs like %s are not supported", name, t)
+		}
+		s.params = append(s.params, t)
+		s.optional = append(s.optional, optional)
+		s.inputs = append(s.inputs, dataName(data))
+	}
+	return s, nil
+}
+
+func isContext(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "context" && n.Obj().Name() == "Context"
+}
+
+// optionalOf returns the type wrapped by t if t is databuilder.Optional, nil otherwise
+func optionalOf(t types.Type) types.Type {
+	n, ok := t.(*types.Named)
+	if !ok || n.Obj().Pkg() == nil || n.Obj().Pkg().Path() != databuilderPath || n.Obj().Name() != "Optional" {
+		return nil
+	}
+	return n.TypeArgs().At(0)
+}
+
+// isData checks if values of type t can be used as data, data should be of a named type that is not an interface or a pointer to one
+func isData(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, ok := t.(*types.Named)
+	if !ok || n.TypeArgs().Len() > 0 || n.Obj().Pkg() == nil {
+		return false
+	}
+	return !types.IsInterface(n)
+}
+
+// isInputs checks if t is an inputs struct, a struct with fields tagged as inputs of the builder
+func isInputs(t types.Type) bool {
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if _, ok := reflect.StructTag(st.Tag(i)).Lookup("databuilder"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dataName returns the name data of type t is identified with, it matches the name databuilder derives with reflection
+func dataName(t types.Type) string {
+	if p, ok := t.(*types.Pointer); ok {
+		return "*" + dataName(p.Elem())
+	}
+	obj := t.(*types.Named).Obj()
+	path := obj.Pkg().Path()
+	if obj.Pkg().Name() == "main" {
+		// reflection reports main as the path of main packages
+		path = "main"
+	}
+	return path + "." + obj.Name()
+}
+
+// imports tracks the packages referenced by the generated code and the names they are imported with
+type imports struct {
+	pkg   *types.Package
+	names map[string]string // mapping between import path and name
+	used  map[string]bool   // names in use
+}
+
+func newImports(pkg *types.Package) *imports {
+	im := &imports{
+		pkg:   pkg,
+		names: make(map[string]string),
+		used:  make(map[string]bool),
+	}
+	im.add("context", "context")
+	im.add(databuilderPath, "databuilder")
+	return im
+}
+
+func (im *imports) add(path, name string) string {
+	if n, ok := im.names[path]; ok {
+		return n
+	}
+	candidate := name
+	for i := 2; im.used[candidate]; i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	im.names[path] = candidate
+	im.used[candidate] = true
+	return candidate
+}
+
+// name returns the name the package with the import path is referred to with
+func (im *imports) name(path string) string {
+	return im.names[path]
+}
+
+func (im *imports) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == im.pkg {
+			return ""
+		}
+		return im.add(p.Path(), p.Name())
+	})
+}
+
+// paths returns the import paths, standard library packages first
+func (im *imports) paths() []string {
+	paths := make([]string, 0, len(im.names))
+	for path := range im.names {
+		paths = append(paths, path)
+	}
+	slices.SortFunc(paths, func(a, b string) int {
+		if isStd(a) != isStd(b) {
+			if isStd(a) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	return paths
+}
+
+func isStd(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
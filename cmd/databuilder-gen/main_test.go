@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	pkg, err := loadPackage("internal/example", "databuilder_gen.go")
+	assert.NoError(t, err)
+	cfg := config{
+		builders: []string{"FetchUser", "FetchPreferences", "Greet", "BuildResponse"},
+		init:     []string{"AppRequest"},
+		targets:  []string{"*AppResponse"},
+		funcName: "NewAppPlan",
+	}
+	src, err := generate(pkg, cfg)
+	assert.NoError(t, err)
+	want, err := os.ReadFile("internal/example/databuilder_gen.go")
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(src), "generated code is out of date, run go generate ./...")
+
+	cfg.targets = []string{"AppResponse"}
+	_, err = generate(pkg, cfg)
+	assert.ErrorContains(t, err, "not an input or output", "*T and T are different data")
+
+	cfg.targets = nil
+	cfg.builders = []string{"FetchUser", "ErrUnknownUser"}
+	_, err = generate(pkg, cfg)
+	assert.ErrorContains(t, err, "is not a function")
+
+	cfg.builders = []string{"ValidateUser"}
+	_, err = generate(pkg, cfg)
+	assert.ErrorContains(t, err, "return error instead of *github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.ValidationError")
+
+	cfg.builders = []string{"GreetNamed"}
+	_, err = generate(pkg, cfg)
+	assert.ErrorContains(t, err, "interface inputs like github.com/go-coldbrew/data-builder/cmd/databuilder-gen/internal/example.Named are not supported")
+
+	cfg.builders = nil
+	_, err = generate(pkg, cfg)
+	assert.Error(t, err)
+}
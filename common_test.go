@@ -95,11 +95,36 @@ func DBTestFuncAfterErr(_ context.Context, s TestStruct2) (TestStruct5, error) {
 	}, nil
 }
 
+func DBTestFuncPointer(_ context.Context, s *TestStruct2) (TestStruct1, error) {
+	return TestStruct1{
+		Value: s.Value,
+	}, nil
+}
+
+type TestError struct {
+	Reason string
+}
+
+func (e TestError) Error() string {
+	return e.Reason
+}
+
+func DBTestFuncPointerError(_ context.Context, s TestStruct1) (TestStruct2, *TestError) {
+	if s.Value == "" {
+		return TestStruct2{}, &TestError{Reason: "empty"}
+	}
+	return TestStruct2{Value: s.Value}, nil
+}
+
+func DBTestFuncStructError(_ context.Context, _ TestStruct1) (TestStruct2, TestError) {
+	return TestStruct2{}, TestError{}
+}
+
 func DBTestFuncInvalid1(_ context.Context, _ int) (TestStruct1, error) {
 	return TestStruct1{}, nil
 }
 
-func DBTestFuncInvalid2(_ context.Context, _ TestStruct2) TestStruct1 {
+func DBTestFuncNoError(_ context.Context, _ TestStruct2) TestStruct1 {
 	return TestStruct1{}
 }
 
@@ -107,10 +132,6 @@ func DBTestFuncInvalid3(_ context.Context, arr ...TestStruct2) (TestStruct1, err
 	return TestStruct1{}, nil
 }
 
-func DBTestFuncInvalid4(_ context.Context, _ *TestStruct2) (TestStruct1, error) {
-	return TestStruct1{}, nil
-}
-
 func DBTestFuncInvalid5(_ context.Context, _ TestStruct1) (TestStruct1, error) {
 	return TestStruct1{}, nil
 }
@@ -135,3 +156,12 @@ func testNew(t *testing.T) *db {
 	}
 	return d
 }
+
+func DBTestFuncMulti(_ context.Context, s TestStruct1) (TestStruct2, TestStruct3, error) {
+	fmt.Println("CALLED DBTestFuncMulti")
+	return TestStruct2{
+		Value: s.Value + "2",
+	}, TestStruct3{
+		Value: s.Value + "3",
+	}, nil
+}
@@ -40,7 +40,7 @@ func GetResultFromCtx(ctx context.Context) Result {
 //
 // this function enables optional access to data, your code should not rely on
 // values being present, if you have explicit dependency please add them to your
-// function parameters
+// function parameters, use Optional for dependencies that may not be present
 func GetFromResult(ctx context.Context, obj any) any {
 	r := GetResultFromCtx(ctx)
 	if r == nil {
@@ -48,3 +48,11 @@ func GetFromResult(ctx context.Context, obj any) any {
 	}
 	return r.Get(obj)
 }
+
+// FromContext is the type-safe version of GetFromResult, it returns the value of type T
+// built by other builders and reports whether it was found
+//
+// the same caveats as GetFromResult apply, your code should not rely on values being present
+func FromContext[T any](ctx context.Context) (T, bool) {
+	return Get[T](GetResultFromCtx(ctx))
+}
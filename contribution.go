@@ -0,0 +1,149 @@
+package databuilder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Contribution is the output of a builder contributing a value to All[T], any number of builders can output
+// Contribution[T] and a builder that takes All[T] as input gets the values of all of them
+//
+//	func StreakBadge(ctx context.Context, u User) (Contribution[Badge], error)
+//	func VIPBadge(ctx context.Context, u User) (Contribution[Badge], error)
+//	func BuildProfile(ctx context.Context, badges All[Badge]) (Profile, error)
+type Contribution[T any] struct {
+	Value T
+}
+
+// All holds the values contributed with Contribution[T], in the order of the names of the builders contributing them.
+// The builder taking All[T] runs after every contributor, contributors that fail are left out.
+// All[T] can only be built when at least one builder outputs Contribution[T]
+type All[T any] struct {
+	Values []T
+}
+
+// contribution is implemented by Contribution[T] to create All[T] from contributions
+type contribution interface {
+	collect(contributions []any) any
+}
+
+func (Contribution[T]) collect(contributions []any) any {
+	all := All[T]{Values: make([]T, 0, len(contributions))}
+	for _, c := range contributions {
+		all.Values = append(all.Values, c.(Contribution[T]).Value)
+	}
+	return all
+}
+
+var (
+	contributionPrefix = dataPrefix(reflect.TypeFor[Contribution[struct{}]]())
+	allPrefix          = dataPrefix(reflect.TypeFor[All[struct{}]]())
+)
+
+// dataPrefix returns the name of the generic type t without its type arguments
+func dataPrefix(t reflect.Type) string {
+	name := getStructName(t)
+	return name[:strings.Index(name, "[")+1]
+}
+
+// isContribution checks if the data is a Contribution[T], the only data more than one builder can output
+func isContribution(name string) bool {
+	return strings.HasPrefix(name, contributionPrefix)
+}
+
+// contributionKey is the name the contribution of a builder is identified with,
+// contributions are kept apart so every contributor can be scheduled on its own
+func contributionKey(name, builder string) string {
+	return name + "#" + builder
+}
+
+// contributes returns the builder with its contributions identified by the name of the builder,
+// the builder itself is returned when it does not contribute
+func (b *builder) contributes(name string) *builder {
+	if !slices.ContainsFunc(b.Out, isContribution) {
+		return b
+	}
+	c := *b
+	c.Out = slices.Clone(b.Out)
+	for i, out := range c.Out {
+		if isContribution(out) {
+			c.Out[i] = contributionKey(out, name)
+		}
+	}
+	return &c
+}
+
+// collectContributions returns the builders with their contributions identified by the name of the builder,
+// along with a builder for every All[T] that collects the contributions to it
+func collectContributions(builders map[string]*builder) (map[string]*builder, error) {
+	type collected struct {
+		keys  []string
+		proto contribution // used to create All[T], the contributions themselves are used when nil
+	}
+	contributions := make(map[string]*collected)
+	result := make(map[string]*builder, len(builders))
+	for name, b := range builders {
+		result[name] = b.contributes(b.Name)
+		for i, out := range b.Out {
+			if !isContribution(out) {
+				continue
+			}
+			all := allPrefix + strings.TrimPrefix(out, contributionPrefix)
+			c, ok := contributions[all]
+			if !ok {
+				c = &collected{}
+				contributions[all] = c
+			}
+			c.keys = append(c.keys, contributionKey(out, b.Name))
+			if i < len(b.outTypes) {
+				c.proto, _ = reflect.Zero(b.outTypes[i]).Interface().(contribution)
+			}
+		}
+	}
+	for all, c := range contributions {
+		for _, b := range result {
+			if slices.Contains(b.Out, all) {
+				return nil, fmt.Errorf("%w: %s is collected from contributions", ErrMultipleBuilderSameOutput, all)
+			}
+		}
+		slices.Sort(c.keys)
+		result[all] = newCollector(all, c.keys, c.proto)
+	}
+	return result, nil
+}
+
+// newCollector returns the builder creating the data all from the contributions identified by keys,
+// contributions are optional inputs so contributors that fail are left out
+func newCollector(all string, keys []string, proto contribution) *builder {
+	b := &builder{
+		Name: all,
+		Out:  []string{all},
+		call: func(_ context.Context, args []any) ([]any, error) {
+			contributions := make([]any, 0, len(args))
+			for _, arg := range args {
+				if _, absent := arg.(absentInput); !absent {
+					contributions = append(contributions, arg)
+				}
+			}
+			p := proto
+			if p == nil && len(contributions) > 0 {
+				p, _ = contributions[0].(contribution)
+			}
+			if p == nil {
+				return nil, fmt.Errorf("no contribution to build %s", all)
+			}
+			return []any{p.collect(contributions)}, nil
+		},
+	}
+	if proto != nil {
+		b.outTypes = []reflect.Type{reflect.TypeOf(proto.collect(nil))}
+	}
+	for _, key := range keys {
+		b.params = append(b.params, param{name: key, optional: absentInput{}})
+	}
+	b.setInputs()
+	return b
+}
@@ -0,0 +1,74 @@
+package databuilder
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+type TestBadge string
+
+func TestContributions(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	errBadge := errors.New("no badge")
+	d := testNew(t)
+	err := d.AddNamedBuilder("b-streak", func(s TestStruct1) Contribution[TestBadge] {
+		return Contribution[TestBadge]{Value: TestBadge("streak:" + s.Value)}
+	})
+	assert.NoError(t, err)
+	err = d.AddNamedBuilder("a-vip", func(TestStruct2) Contribution[TestBadge] {
+		return Contribution[TestBadge]{Value: "vip"}
+	})
+	assert.NoError(t, err, "contributions can be output by more than one builder")
+	err = d.AddNamedBuilder("c-broken", func(TestStruct1) (Contribution[TestBadge], error) {
+		return Contribution[TestBadge]{}, errBadge
+	})
+	assert.NoError(t, err)
+	err = d.AddBuilders(DBTestFunc, func(badges All[TestBadge]) TestStruct3 {
+		value := ""
+		for _, b := range badges.Values {
+			value += string(b) + ";"
+		}
+		return TestStruct3{Value: value}
+	})
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 3, TestStruct1{Value: "a_b"})
+	assert.ErrorIs(t, err, errBadge, "the error of the contributor should be reported")
+	assert.NotErrorIs(t, err, ErrDependencyFailed, "the consumer should not be skipped")
+	assert.Equal(t, "vip;streak:a_b;", MustGet[TestStruct3](result).Value, "contributions should be ordered by builder name")
+
+	executionPlan, err = d.CompileFor([]any{TestStruct3{}}, TestStruct1{})
+	assert.NoError(t, err)
+	err = executionPlan.Replace(context.Background(), "c-broken", func(TestStruct1) Contribution[TestBadge] {
+		return Contribution[TestBadge]{Value: "fixed"}
+	})
+	assert.NoError(t, err, "contributors can be replaced")
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a_b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "vip;streak:a_b;fixed;", MustGet[TestStruct3](result).Value, "contributors should run before the consumer")
+}
+
+func TestContributionsValidation(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(func(badges All[TestBadge]) TestStruct3 {
+		return TestStruct3{}
+	})
+	assert.NoError(t, err)
+	_, err = d.Compile()
+	assert.Error(t, err, "All needs at least one contributor")
+
+	err = d.AddBuilders(func(TestStruct1) Contribution[TestBadge] {
+		return Contribution[TestBadge]{}
+	}, func(TestStruct2) All[TestBadge] {
+		return All[TestBadge]{}
+	})
+	assert.NoError(t, err)
+	_, err = d.Compile(TestStruct1{}, TestStruct2{})
+	assert.ErrorIs(t, err, ErrMultipleBuilderSameOutput, "All can not be built by builders when it is collected")
+}
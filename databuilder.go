@@ -2,9 +2,13 @@ package databuilder
 
 import (
 	"context"
+	"fmt"
+	"maps"
 	"reflect"
 	"runtime"
-
+	"slices"
+	"strings"
+	"sync"
 )
 
 /*
@@ -15,16 +19,80 @@ import (
 // Downstream packages reference this to enforce version compatibility.
 const SupportPackageIsVersion1 = true
 
+var (
+	contextType = reflect.TypeFor[context.Context]()
+	errorType   = reflect.TypeFor[error]()
+)
+
 type builder struct {
-	fnValue reflect.Value // cached reflect.ValueOf(builder func) to avoid repeated reflection
-	In      []string
-	Out     string
-	Name    string
+	builderConfig
+	fnValue  reflect.Value  // cached reflect.ValueOf(builder func) to avoid repeated reflection
+	In       []string       // inputs that are required for the builder to run
+	Optional []string       // inputs that are passed as Optional, the builder runs even if they are absent
+	Out      []string       // outputs of the builder, in the order they are returned
+	outTypes []reflect.Type // types of the outputs, in the order they are returned
+	Name     string
+	named    bool            // Name was set explicitly with WithName
+	params   []param         // parameters of the builder function after context.Context
+	opts     []BuilderOption // options the builder was configured with
+	// call calls the builder function with the values of params and returns the data outputs
+	call func(ctx context.Context, args []any) ([]any, error)
+	// each is set for fan-out builders, plans call it once per element instead of calling call
+	each *fanOut
+	// alternative builders can share their outputs with other alternatives, Compile keeps the one with the highest priority
+	alternative bool
+	priority    int
+	// variants are set for builders created with OneOf, plans call the variant chosen for the run
+	variants []variant
+	// shadow is set for builders created with Shadow, its candidate runs in the background after the builder
+	shadow *shadow
+}
+
+// param describes how a parameter of the builder function is filled
+type param struct {
+	name         string
+	optional     optionalInput // the zero value of the Optional type of the parameter, nil for required inputs
+	zeroIfAbsent bool          // the input is optional and the zero value is passed when it is absent
+	inject       *injection    // the fields of an inputs struct, set instead of name
+	iface        reflect.Type  // the interface type of the input, bound to the data implementing it when compiled
+}
+
+// isOptional reports whether the builder runs when the input of the param is absent
+func (p *param) isOptional() bool {
+	return p.optional != nil || p.zeroIfAbsent
+}
+
+// inputs returns the params that are filled with a single input, fields of inputs structs included
+func (b *builder) inputs() []*param {
+	inputs := make([]*param, 0, len(b.params))
+	for i := range b.params {
+		if inj := b.params[i].inject; inj != nil {
+			for j := range inj.fields {
+				inputs = append(inputs, &inj.fields[j].param)
+			}
+			continue
+		}
+		inputs = append(inputs, &b.params[i])
+	}
+	return inputs
+}
+
+// setInputs sets the required and optional inputs of the builder from its params
+func (b *builder) setInputs() {
+	b.In, b.Optional = nil, nil
+	for _, p := range b.inputs() {
+		if p.isOptional() {
+			b.Optional = append(b.Optional, p.name)
+		} else {
+			b.In = append(b.In, p.name)
+		}
+	}
 }
 
 type db struct {
 	builders map[string]*builder
 	outSet   stringSet
+	opts     options
 }
 
 func (d *db) AddBuilders(builders ...any) error {
@@ -49,52 +117,217 @@ func (d *db) AddBuilders(builders ...any) error {
 	return nil
 }
 
+func (d *db) AddNamedBuilder(name string, fn any) error {
+	if fn == nil {
+		return ErrInvalidBuilder
+	}
+	return d.AddBuilders(Configure(fn, WithName(name)))
+}
+
 func (d *db) add(bldr any) error {
 	b, err := getBuilder(bldr)
 	if err != nil {
 		return err
 	}
+	return d.insert(b)
+}
 
+func (d *db) insert(b *builder) error {
 	// check for name
-	if _, ok := d.builders[b.Name]; ok {
+	if existing, ok := d.builders[b.Name]; ok {
+		if b.named || existing.named || isAnonymous(b.Name) || !existing.sameKind(b) {
+			// the name does not tell us if this is the same builder
+			return fmt.Errorf("%w: %s", ErrBuilderNameConflict, b.Name)
+		}
+		// same function added again
 		return nil
 	}
 
 	//check for outSet
-	if d.outSet.Has(b.Out) {
-		return ErrMultipleBuilderSameOutput
+	for _, out := range b.Out {
+		if d.outSet.Has(out) && !isContribution(out) && !(b.alternative && d.isAlternative(out)) {
+			return ErrMultipleBuilderSameOutput
+		}
 	}
 
 	d.builders[b.Name] = b
-	d.outSet.Insert(b.Out)
+	d.outSet.Insert(b.Out...)
 	return nil
 }
 
+// sameKind checks if builders sharing a name build the same outputs from the same inputs the same way,
+// the same element function can for instance be used by fan-out builders of different collections
+// and a builder shares its name with the builder created when it is shadowed. Builders created with OneOf
+// or Shadow are never the same, their selectors and candidates can not be compared
+func (b *builder) sameKind(other *builder) bool {
+	return slices.Equal(b.In, other.In) && slices.Equal(b.Optional, other.Optional) && slices.Equal(b.Out, other.Out) &&
+		(b.each == nil) == (other.each == nil) && len(b.variants) == 0 && len(other.variants) == 0 &&
+		b.shadow == nil && other.shadow == nil
+}
+
 func (d *db) Compile(init ...any) (Plan, error) {
-	initialialData := make([]string, 0, len(init))
-	for _, inter := range init {
+	initialialData, err := getDataNames(init)
+	if err != nil {
+		return nil, err
+	}
+	return d.compile(nil, initialialData, getDataTypes(init))
+}
+
+func (d *db) CompileFor(targets []any, init ...any) (Plan, error) {
+	initialialData, err := getDataNames(init)
+	if err != nil {
+		return nil, err
+	}
+	targetData, err := getDataNames(targets)
+	if err != nil {
+		return nil, err
+	}
+	return d.compile(targetData, initialialData, getDataTypes(init))
+}
+
+// compile creates a plan for the targets from the initial data, all builders are part of the plan when targets is nil
+func (d *db) compile(targets, initialialData []string, initTypes map[string]reflect.Type) (Plan, error) {
+	builders, err := collectContributions(d.builders)
+	if err != nil {
+		return nil, err
+	}
+	// interfaces and outputs that can be satisfied in more than one way, only the ones needed are reported
+	ambiguous := make(map[string]error)
+	builders, bindings, interfaces := bindInterfaces(builders, initTypes)
+	maps.Copy(ambiguous, interfaces)
+	builders, outputs := chooseAlternatives(builders, initialialData)
+	maps.Copy(ambiguous, outputs)
+	if targets != nil {
+		selected, err := selectBuilders(builders, targets, initialialData...)
+		if err != nil {
+			if aErr := ambiguityError(nil, targets, ambiguous); aErr != nil {
+				// the target has producers, none of them could be chosen
+				return nil, aErr
+			}
+			return nil, err
+		}
+		builders = selected
+	}
+	if err := ambiguityError(builders, targets, ambiguous); err != nil {
+		return nil, err
+	}
+	order, err := resolveDependencies(builders, initialialData...)
+	if err != nil {
+		return nil, err
+	}
+	return newPlan(order, initialialData, bindings, d.opts)
+}
+
+// isAnonymous checks if the function name is shared by different functions,
+// closures are named after the function they are created in, e.g. pkg.makeFetcher.func1
+// and method values after the method, e.g. pkg.(*Client).Fetch-fm
+func isAnonymous(name string) bool {
+	if strings.HasSuffix(name, "-fm") {
+		return true
+	}
+	i := strings.LastIndex(name, ".func")
+	if i < 0 {
+		return false
+	}
+	for _, r := range name[i+len(".func"):] {
+		if r != '.' && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// getDataNames returns the names of the data types provided, nil values are ignored
+func getDataNames(data []any) ([]string, error) {
+	names := make([]string, 0, len(data))
+	for _, inter := range data {
 		if inter == nil {
 			continue
 		}
-		t := reflect.TypeOf(inter)
-		if t.Kind() != reflect.Struct {
-			return nil, ErrInvalidBuilderInput
+		name, _, err := getData(inter)
+		if err != nil {
+			return nil, err
 		}
-		initialialData = append(initialialData, getStructName(t))
+		names = append(names, name)
 	}
+	return names, nil
+}
 
-	order, err := resolveDependencies(d.builders, initialialData...)
-	if err != nil {
-		return nil, err
+// getData returns the name and the value of data provided to Compile or Run
+func getData(inter any) (string, any, error) {
+	if v, ok := inter.(*namedValue); ok {
+		if v.qualifier == "" || v.value == nil {
+			return "", nil, ErrInvalidBuilderInput
+		}
+		name, value, err := getData(v.value)
+		if err != nil {
+			return "", nil, err
+		}
+		return qualifiedName(name, v.qualifier), value, nil
 	}
-	return newPlan(order, initialialData)
+	t := reflect.TypeOf(inter)
+	if !isDataType(t) {
+		return "", nil, ErrInvalidBuilderInput
+	}
+	return getStructName(t), inter, nil
 }
 
 // IsValidBuilder checks if the given function is valid or not
+//
+// a builder is a function of the form func(context.Context, In...) (Out..., error), the context and the error
+// are optional so pure transformations can be registered as they are, e.g. func(In) Out, func(In) (Out, error)
+// and func(context.Context, In) Out are all valid builders. The error can be any interface or pointer type
+// implementing error, e.g. func(In) (Out, *ValidationError), a nil error reports success.
+//
+// a builder can return multiple outputs before the error, e.g. func(context.Context, In) (A, B, error),
+// each of the outputs is data of its own that can only be produced by one builder.
+//
+// inputs and outputs of builders should be named types or pointers to them, e.g. structs, generated protobuf
+// messages or type FeatureFlags map[string]bool. Unnamed and predeclared types like []int64 or string are not
+// accepted as different data of the same type could not be told apart. *T and T are different data, a builder with an input of *T is only fed by a builder that outputs *T.
+// A builder returning a nil pointer without an error fails with ErrNilOutput.
+//
+// inputs can also be named interfaces with methods, an interface input is fed by the only builder output or
+// initial data that implements it. The data is chosen when the plan is compiled, compiling fails with
+// ErrAmbiguousDependency when more than one implements the interface and a builder depending on it is needed, e.g.
+//
+//	type PricingSource interface{ Price(sku string) int64 }
+//
+//	func Checkout(ctx context.Context, cart Cart, prices PricingSource) (Total, error)
+//
+// builders with many inputs can take an inputs struct instead, a struct whose fields tagged with databuilder
+// are the inputs of the builder in place of the struct itself
+//
+//	type ResponseInputs struct {
+//		User     User             `databuilder:"in"`
+//		Location Location         `databuilder:"in,optional"`     // zero value when Location is absent
+//		Billing  Address          `databuilder:"in,name=billing"` // Address@billing, see WithQualifiedInput
+//		Badges   Optional[Badges] `databuilder:"in"`
+//	}
+//
+//	func BuildResponse(ctx context.Context, in ResponseInputs) (AppResponse, error)
 func IsValidBuilder(builder any) error {
+	if s, ok := builder.(*builderSpec); ok {
+		builder = s.fn
+	}
 	if builder == nil {
 		return ErrInvalidBuilder
 	}
+	if vs, ok := builder.(*variantSpec); ok {
+		_, err := vs.builder()
+		return err
+	}
+	if ss, ok := builder.(*shadowSpec); ok {
+		_, err := ss.builder()
+		return err
+	}
+	if tb, ok := builder.(*typedBuilder); ok {
+		outputs, err := validateOutputs(tb.outs)
+		if err != nil {
+			return err
+		}
+		return validateInputs(tb.ins, outputs)
+	}
 	t := reflect.TypeOf(builder)
 	if t.Kind() != reflect.Func {
 		// Input can only be a function
@@ -103,78 +336,266 @@ func IsValidBuilder(builder any) error {
 	if reflect.ValueOf(builder).IsNil() {
 		return ErrInvalidBuilder
 	}
-	if t.NumOut() != 2 {
-		// should return a struct and an error
+	outs := outputTypes(t)
+	if len(outs) == 0 {
+		// should return at least one value besides the error
 		return ErrInvalidBuilderNumOutput
 	}
-	if t.Out(0).Kind() != reflect.Struct {
-		// first return argument should always be a struct
-		return ErrInvalidBuilderFirstOutput
-	}
-	if t.Out(1).Kind() != reflect.Interface {
-		// second return argument should always be an Interface
+	if last := outs[len(outs)-1]; !returnsError(t) && (len(outs) > 1 && !isDataType(last) || last.Implements(errorType)) {
+		// last return argument should be an error when it is not data, errors that can not be nil are neither
 		return ErrInvalidBuilderSecondOutput
 	}
-	if !t.Out(1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
-		// second return argument should always be an error
-		return ErrInvalidBuilderSecondOutput
+	outputs, err := validateOutputs(outs)
+	if err != nil {
+		return err
+	}
+	if t.IsVariadic() {
+		return ErrInvalidBuilderInput
+	}
+	return validateInputs(inputTypes(t), outputs)
+}
+
+// takesContext checks if the first parameter of the builder function type t is context.Context
+func takesContext(t reflect.Type) bool {
+	return t.NumIn() > 0 && t.In(0).Kind() == reflect.Interface && t.In(0).Implements(contextType)
+}
+
+// returnsError checks if the last return type of the builder function type t is an error,
+// error types should be interfaces or pointers so a nil value reports success
+func returnsError(t reflect.Type) bool {
+	if t.NumOut() == 0 {
+		return false
 	}
-	if t.NumIn() > 0 {
-		// first input should always be context.Context
-		if t.In(0).Kind() != reflect.Interface {
-			return ErrInvalidBuilderMissingContext
+	last := t.Out(t.NumOut() - 1)
+	return (last.Kind() == reflect.Interface || last.Kind() == reflect.Pointer) && last.Implements(errorType)
+}
+
+// validateOutputs checks the data outputs of a builder and returns their names
+func validateOutputs(outs []reflect.Type) (stringSet, error) {
+	outputs := newStringSet()
+	for _, out := range outs {
+		if !isDataType(out) {
+			// other return arguments should always be data
+			return nil, ErrInvalidBuilderFirstOutput
 		}
-		if !t.In(0).Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
-			return ErrInvalidBuilderMissingContext
+		name := getStructName(out)
+		if outputs.Has(name) {
+			return nil, ErrDuplicateOutput
 		}
-		// other inputs should all be structs
-		for i := 1; i < t.NumIn(); i++ {
-			if t.In(i).Kind() != reflect.Struct {
-				// checks for vardic functions as well
-				return ErrInvalidBuilderInput
+		outputs.Insert(name)
+	}
+	return outputs, nil
+}
+
+// validateInputs checks the data inputs of a builder against its outputs
+func validateInputs(ins []reflect.Type, outputs stringSet) error {
+	// inputs should all be data
+	for _, in := range ins {
+		if in.Kind() == reflect.Interface && in.Implements(contextType) {
+			// context.Context can only be the first input
+			return ErrInvalidBuilderInput
+		}
+		inj, err := getInjection(in)
+		if err != nil {
+			return err
+		}
+		if inj != nil {
+			for _, f := range inj.fields {
+				if outputs.Has(f.name) {
+					return ErrSameInputAsOutput
+				}
 			}
-			if getStructName(t.In(i)) == getStructName(t.Out(0)) {
-				return ErrSameInputAsOutput
+			continue
+		}
+		if !isInputType(in) {
+			return ErrInvalidBuilderInput
+		}
+		if o := getOptionalOf(in); o != nil {
+			// optional inputs should wrap data
+			if !isInputType(o) {
+				return ErrInvalidBuilderInput
 			}
+			in = o
+		}
+		if outputs.Has(getStructName(in)) {
+			return ErrSameInputAsOutput
 		}
-	} else {
-		return ErrInvalidBuilderMissingContext
 	}
-
 	return nil
 }
 
+// inputTypes returns the data inputs of the builder function type t, context.Context is skipped
+func inputTypes(t reflect.Type) []reflect.Type {
+	ins := make([]reflect.Type, 0, t.NumIn())
+	first := 0
+	if takesContext(t) {
+		first = 1
+	}
+	for i := first; i < t.NumIn(); i++ {
+		ins = append(ins, t.In(i))
+	}
+	return ins
+}
+
+// outputTypes returns the data outputs of the builder function type t, the error is skipped
+func outputTypes(t reflect.Type) []reflect.Type {
+	outs := make([]reflect.Type, 0, t.NumOut())
+	last := t.NumOut()
+	if returnsError(t) {
+		last--
+	}
+	for i := 0; i < last; i++ {
+		outs = append(outs, t.Out(i))
+	}
+	return outs
+}
+
 func getBuilder(bldr any) (*builder, error) {
+	if s, ok := bldr.(*builderSpec); ok {
+		b, err := getBuilder(s.fn)
+		if err != nil {
+			return nil, err
+		}
+		for _, opt := range s.opts {
+			if opt == nil {
+				continue
+			}
+			if err := opt(b); err != nil {
+				return nil, err
+			}
+		}
+		b.opts = s.opts
+		return b, nil
+	}
+	if s, ok := bldr.(*variantSpec); ok {
+		return s.builder()
+	}
+	if s, ok := bldr.(*shadowSpec); ok {
+		return s.builder()
+	}
 	if err := IsValidBuilder(bldr); err != nil {
 		return nil, err
 	}
+	if tb, ok := bldr.(*typedBuilder); ok {
+		b := newBuilder(reflect.ValueOf(tb.fn), tb.ins, tb.outs)
+		b.call = tb.call
+		b.each = tb.each
+		return b, nil
+	}
 
 	fnValue := reflect.ValueOf(bldr)
 	if fnValue.IsNil() {
 		return nil, ErrInvalidBuilder
 	}
-
 	t := fnValue.Type()
-	out := getStructName(t.Out(0))
-	name := runtime.FuncForPC(fnValue.Pointer()).Name()
+	b := newBuilder(fnValue, inputTypes(t), outputTypes(t))
+	withContext, withError := takesContext(t), returnsError(t)
+	b.call = func(ctx context.Context, args []any) ([]any, error) {
+		in := make([]reflect.Value, 0, len(args)+1)
+		if withContext {
+			in = append(in, reflect.ValueOf(ctx))
+		}
+		for _, arg := range args {
+			in = append(in, reflect.ValueOf(arg))
+		}
+		values := fnValue.Call(in)
+		if !withError {
+			outputs := make([]any, 0, len(values))
+			for _, v := range values {
+				outputs = append(outputs, v.Interface())
+			}
+			return outputs, nil
+		}
+		// the last output is the error, data comes before it
+		outputs := make([]any, 0, len(values)-1)
+		for _, v := range values[:len(values)-1] {
+			outputs = append(outputs, v.Interface())
+		}
+		last := values[len(values)-1]
+		if last.IsNil() {
+			return outputs, nil
+		}
+		lastReturn := last.Interface()
+		err, ok := lastReturn.(error)
+		if !ok {
+			err = fmt.Errorf("last return value is not an error (type %T)", lastReturn)
+		}
+		return outputs, err
+	}
+	return b, nil
+}
 
+// newParam returns the param filling a parameter of type t
+func newParam(t reflect.Type) param {
+	var p param
+	if o := getOptionalOf(t); o != nil {
+		p.optional = reflect.Zero(t).Interface().(optionalInput)
+		t = o
+	}
+	if isInterfaceInput(t) {
+		p.iface = t
+	}
+	p.name = getStructName(t)
+	return p
+}
+
+// newBuilder creates a builder for the function fnValue with the given data inputs and outputs,
+// the caller sets how the function is called
+func newBuilder(fnValue reflect.Value, ins, outs []reflect.Type) *builder {
 	b := &builder{
-		Out:     out,
 		fnValue: fnValue,
-		Name:    name,
+		Name:    runtime.FuncForPC(fnValue.Pointer()).Name(),
 	}
-	// first in context.Context so we start from second
-	for i := 1; i < t.NumIn(); i++ {
-		b.In = append(b.In, getStructName(t.In(i)))
+	for _, out := range outs {
+		b.Out = append(b.Out, getStructName(out))
+		b.outTypes = append(b.outTypes, out)
 	}
-	return b, nil
+	for _, in := range ins {
+		if inj, _ := getInjection(in); inj != nil {
+			b.params = append(b.params, param{inject: inj})
+			continue
+		}
+		b.params = append(b.params, newParam(in))
+	}
+	b.setInputs()
+	return b
 }
 
+// structNames caches the names returned by getStructName, names are looked up on every run
+var structNames sync.Map // map[reflect.Type]string
+
+// getStructName returns the name data of type t is identified with, *T and T are different data
 func getStructName(t reflect.Type) string {
-	return t.PkgPath() + "." + t.Name()
+	if name, ok := structNames.Load(t); ok {
+		return name.(string)
+	}
+	var name string
+	if t.Kind() == reflect.Pointer {
+		name = "*" + getStructName(t.Elem())
+	} else {
+		name = t.PkgPath() + "." + t.Name()
+	}
+	structNames.Store(t, name)
+	return name
+}
+
+// isDataType checks if values of type t can be used as data, data should be of a named type that is not an
+// interface, e.g. a struct or type UserIDs []int64, or a pointer to one. Unnamed types like []int64 and
+// predeclared types like string are not data as different data of the same type could not be told apart
+func isDataType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name() != "" && t.PkgPath() != "" && t.Kind() != reflect.Interface
 }
 
 // New Creates a new DataBuilder
-func New() DataBuilder {
-	return &db{}
+func New(opts ...Option) DataBuilder {
+	d := &db{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&d.opts)
+		}
+	}
+	return d
 }
@@ -2,6 +2,8 @@ package databuilder
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,13 +17,15 @@ func TestValidBuilder(t *testing.T) {
 	assert.NoError(t, IsValidBuilder(DBTestFunc), "DBTestFunc should be valid")
 	assert.NoError(t, IsValidBuilder(DBTestFunc2), "DBTestFunc2 should be valid")
 	assert.Error(t, IsValidBuilder(DBTestFuncInvalid1), "DBTestFuncInvalid1 should NOT be valid")
-	assert.Error(t, IsValidBuilder(DBTestFuncInvalid2), "DBTestFuncInvalid2 should NOT be valid")
+	assert.NoError(t, IsValidBuilder(DBTestFuncNoError), "builders do not need to return an error")
 	assert.Error(t, IsValidBuilder(DBTestFuncInvalid3), "DBTestFuncInvalid3 should NOT be valid")
-	assert.Error(t, IsValidBuilder(DBTestFuncInvalid4), "DBTestFuncInvalid4 should NOT be valid")
+	assert.NoError(t, IsValidBuilder(DBTestFuncPointer), "pointers to structs should be valid")
 	assert.Error(t, IsValidBuilder(DBTestFuncInvalid5), "DBTestFuncInvalid5 should NOT be valid")
 	assert.Error(t, IsValidBuilder(DBTestFuncInvalid6), "DBTestFuncInvalid6 should NOT be valid")
 	assert.Error(t, IsValidBuilder(DBTestFuncInvalid7), "DBTestFuncInvalid7 should NOT be valid")
 	assert.Error(t, IsValidBuilder(DBTestFuncInvalid8), "DBTestFuncInvalid8 should NOT be valid")
+	assert.NoError(t, IsValidBuilder(DBTestFuncPointerError), "pointers implementing error should be the error")
+	assert.ErrorIs(t, IsValidBuilder(DBTestFuncStructError), ErrInvalidBuilderSecondOutput, "errors that can not be nil should NOT be valid")
 	var intVal int = 1
 	assert.Error(t, IsValidBuilder(intVal), "Non function values should NOT be valid")
 	assert.Error(t, IsValidBuilder(TestStruct2{}), "Non function values should NOT be valid")
@@ -107,3 +111,198 @@ func TestJoinErrors(t *testing.T) {
 	assert.ErrorIs(t, err, ErrWTF)
 	assert.ErrorIs(t, err, ErrInvalidBuilder)
 }
+
+func TestCompileFor(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFunc, DBTestFunc4, DBTestFunc7, DBTestFuncAfterErr)
+	assert.NoError(t, err)
+
+	_, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	p, err := d.CompileFor([]any{TestStruct4{}}, TestStruct1{})
+	assert.NoError(t, err)
+	result, err := p.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a-b", MustGet[TestStruct4](result).Value)
+	_, ok := Get[TestStruct2](result)
+	assert.False(t, ok, "builders not needed for the target should not run")
+	_, ok = Get[TestStruct5](result)
+	assert.False(t, ok, "builders not needed for the target should not run")
+
+	_, err = d.CompileFor([]any{TestStruct5{}}, TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = d.CompileFor([]any{TestStruct4{}})
+	assert.ErrorIs(t, err, ErrCouldNotResolveDependency, "ancestors should still need initial data")
+
+	_, err = d.CompileFor([]any{AppResponse{}}, TestStruct1{})
+	assert.ErrorIs(t, err, ErrNoProducer)
+
+	_, err = d.CompileFor([]any{0}, TestStruct1{})
+	assert.ErrorIs(t, err, ErrInvalidBuilderInput)
+}
+
+func TestValidBuilderMultipleOutputs(t *testing.T) {
+	duplicate := func(_ context.Context, _ TestStruct1) (TestStruct2, TestStruct2, error) {
+		return TestStruct2{}, TestStruct2{}, nil
+	}
+	sameAsInput := func(_ context.Context, _ TestStruct1) (TestStruct2, TestStruct1, error) {
+		return TestStruct2{}, TestStruct1{}, nil
+	}
+	noError := func(_ context.Context, _ TestStruct1) (TestStruct2, TestStruct3, TestStruct4) {
+		return TestStruct2{}, TestStruct3{}, TestStruct4{}
+	}
+	notData := func(_ context.Context, _ TestStruct1) (TestStruct2, TestStruct3, int) {
+		return TestStruct2{}, TestStruct3{}, 0
+	}
+	assert.NoError(t, IsValidBuilder(DBTestFuncMulti))
+	assert.ErrorIs(t, IsValidBuilder(duplicate), ErrDuplicateOutput)
+	assert.ErrorIs(t, IsValidBuilder(sameAsInput), ErrSameInputAsOutput)
+	assert.NoError(t, IsValidBuilder(noError), "builders do not need to return an error")
+	assert.ErrorIs(t, IsValidBuilder(notData), ErrInvalidBuilderSecondOutput)
+
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFunc4)
+	assert.NoError(t, err)
+	err = d.AddBuilders(DBTestFuncMulti)
+	assert.ErrorIs(t, err, ErrMultipleBuilderSameOutput, "every output should only be produced once")
+}
+
+func makeAppender(suffix string) func(context.Context, TestStruct1) (TestStruct2, error) {
+	return func(_ context.Context, s TestStruct1) (TestStruct2, error) {
+		return TestStruct2{Value: s.Value + suffix}, nil
+	}
+}
+
+func TestBuilderNames(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(makeAppender("!"))
+	assert.NoError(t, err)
+	err = d.AddBuilders(DBTestFunc4, DBTestFunc4)
+	assert.NoError(t, err, "the same function can be added again")
+	err = d.AddBuilders(Named("x", makeAppender("?")))
+	assert.NoError(t, err, "qualified closures have names of their own")
+
+	err = d.AddBuilders(makeAppender("?"))
+	assert.ErrorIs(t, err, ErrBuilderNameConflict, "closures of the same function should not be dropped")
+	err = d.AddNamedBuilder("github.com/go-coldbrew/data-builder.DBTestFunc4", DBTestFunc6)
+	assert.ErrorIs(t, err, ErrBuilderNameConflict)
+	err = d.AddNamedBuilder("", DBTestFunc6)
+	assert.ErrorIs(t, err, ErrInvalidOption)
+
+	d = testNew(t)
+	err = d.AddNamedBuilder("append", makeAppender("!"))
+	assert.NoError(t, err)
+	err = d.AddNamedBuilder("append", makeAppender("!"))
+	assert.ErrorIs(t, err, ErrBuilderNameConflict, "named builders can not be told apart")
+	err = d.AddBuilders(Configure(makeAppender("?"), WithName("other")))
+	assert.ErrorIs(t, err, ErrMultipleBuilderSameOutput)
+
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a!", MustGet[TestStruct2](result).Value)
+
+	err = executionPlan.Replace(context.Background(), "append", makeAppender("?"))
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a?", MustGet[TestStruct2](result).Value)
+
+	err = executionPlan.Replace(context.Background(), "append", DBTestFunc)
+	assert.NoError(t, err, "the name should be kept after replacing")
+	err = executionPlan.Replace(context.Background(), "unknown", DBTestFunc)
+	assert.Error(t, err)
+}
+
+type TestIDs []int64
+
+type TestFlags map[int64]bool
+
+type TestCount int
+
+func DBTestFuncFlags(_ context.Context, ids TestIDs) (TestFlags, TestCount, error) {
+	flags := make(TestFlags, len(ids))
+	for _, id := range ids {
+		flags[id] = id%2 == 0
+	}
+	return flags, TestCount(len(ids)), nil
+}
+
+func DBTestFuncCount(_ context.Context, flags TestFlags, count *TestCount) (TestStruct1, error) {
+	return TestStruct1{Value: fmt.Sprintf("%d/%d", len(flags), *count)}, nil
+}
+
+func TestNamedNonStructTypes(t *testing.T) {
+	assert.NoError(t, IsValidBuilder(DBTestFuncFlags))
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, []int64) (TestStruct1, error) { return TestStruct1{}, nil }), ErrInvalidBuilderInput)
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, TestStruct1) (string, error) { return "", nil }), ErrInvalidBuilderFirstOutput)
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, TestStruct1) (*[]int64, error) { return nil, nil }), ErrInvalidBuilderFirstOutput)
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, TestStruct1) (TestInter, error) { return nil, nil }), ErrInvalidBuilderFirstOutput)
+
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFuncFlags, DBTestFuncCount, func(_ context.Context, c TestCount) (*TestCount, error) {
+		return &c, nil
+	})
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestIDs{})
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestIDs{1, 2, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, TestFlags{1: false, 2: true, 3: false}, result.Get(TestFlags(nil)))
+	count, ok := Get[TestCount](result)
+	assert.True(t, ok)
+	assert.Equal(t, TestCount(3), count)
+	assert.Equal(t, "3/3", MustGet[TestStruct1](result).Value)
+
+	_, err = d.Compile([]int64{})
+	assert.ErrorIs(t, err, ErrInvalidBuilderInput, "unnamed types are not data")
+}
+
+func TestBuilderWithoutContextOrError(t *testing.T) {
+	pure := func(s TestStruct1) TestStruct2 {
+		return TestStruct2{Value: s.Value + " pure"}
+	}
+	failing := func(s TestStruct2) (TestStruct3, error) {
+		return TestStruct3{Value: s.Value}, errors.New("failing")
+	}
+	withContext := func(ctx context.Context, s TestStruct2) *TestStruct4 {
+		return &TestStruct4{Value: s.Value + fmt.Sprint(ctx != nil)}
+	}
+	assert.ErrorIs(t, IsValidBuilder(func(TestStruct1) error { return nil }), ErrInvalidBuilderNumOutput)
+	assert.ErrorIs(t, IsValidBuilder(func(TestStruct1) {}), ErrInvalidBuilderNumOutput)
+	assert.ErrorIs(t, IsValidBuilder(func(TestStruct1, context.Context) TestStruct2 { return TestStruct2{} }), ErrInvalidBuilderInput, "context should be the first input")
+	assert.NoError(t, IsValidBuilder(func() TestStruct1 { return TestStruct1{} }))
+
+	d := testNew(t)
+	err := d.AddBuilders(pure, Configure(failing, WithDefault(TestStruct3{Value: "default"})), withContext)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestStruct1{Value: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a pure", MustGet[TestStruct2](result).Value)
+	assert.Equal(t, "default", MustGet[TestStruct3](result).Value)
+	assert.Equal(t, "a puretrue", MustGet[*TestStruct4](result).Value)
+
+	err = executionPlan.Replace(context.Background(), withContext, func(_ context.Context, s TestStruct2) (*TestStruct4, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a"})
+	assert.ErrorIs(t, err, ErrNilOutput)
+
+	d = testNew(t)
+	err = d.AddBuilders(DBTestFuncPointerError)
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a"})
+	assert.NoError(t, err, "a nil error pointer should report success")
+	assert.Equal(t, "a", MustGet[TestStruct2](result).Value)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	var tErr *TestError
+	assert.True(t, errors.As(err, &tErr))
+}
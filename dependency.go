@@ -2,7 +2,6 @@ package databuilder
 
 import (
 	"fmt"
-
 )
 
 // resolveDependencies resolves the dependencies between the builders
@@ -10,22 +9,36 @@ import (
 // The order is a list of lists of builders. Each list of builders
 // can be executed in parallel. The order of the lists is the order
 // in which the builders should be executed.
+// Optional inputs are only taken into account when they are produced by one of the builders.
 // The function returns an error if the dependencies cannot be resolved.
 func resolveDependencies(mapping map[string]*builder, initData ...string) ([][]*builder, error) {
 	/*
 	 * dependency resolution is NP problem, lets see what we can do
 	 */
-	outputMap := make(map[string]string)      // mapping between function return and function
+	outputMap := make(map[string]string)    // mapping between function return and function
 	structMap := make(map[string]stringSet) // mapping between output struct and input struct
 	for _, v := range mapping {
-		outputMap[v.Out] = v.Name
-		if _, ok := structMap[v.Out]; !ok {
-			structMap[v.Out] = newStringSet()
+		for _, out := range v.Out {
+			outputMap[out] = v.Name
+		}
+	}
+	for _, v := range mapping {
+		for _, out := range v.Out {
+			if _, ok := structMap[out]; !ok {
+				structMap[out] = newStringSet()
+			}
+			structMap[out].Insert(v.In...)
+			for _, in := range v.Optional {
+				if _, ok := outputMap[in]; ok {
+					// optional inputs that are built need to be built first
+					structMap[out].Insert(in)
+				}
+			}
 		}
-		structMap[v.Out].Insert(v.In...)
 	}
 
 	readyset := newStringSet(initData...)
+	added := newStringSet() // builders already in the order
 	order := make([][]*builder, 0)
 	for len(structMap) > 0 {
 		blocked := newStringSet()
@@ -46,8 +59,13 @@ func resolveDependencies(mapping map[string]*builder, initData ...string) ([][]*
 				// skip already provided fields
 				continue
 			}
-			o = append(o, mapping[fn])
 			delete(structMap, v)
+			if added.Has(fn) {
+				// builders with multiple outputs are added once
+				continue
+			}
+			added.Insert(fn)
+			o = append(o, mapping[fn])
 		}
 		order = append(order, o)
 		for k, v := range structMap {
@@ -58,3 +76,46 @@ func resolveDependencies(mapping map[string]*builder, initData ...string) ([][]*
 	}
 	return order, nil
 }
+
+// selectBuilders returns the builders that are needed to produce the targets.
+// It walks backwards from the targets through the builders producing their inputs,
+// builders that do not contribute to any of the targets are left out.
+// The function returns an error if a target is neither produced by a builder nor part of the initial data.
+func selectBuilders(mapping map[string]*builder, targets []string, initData ...string) (map[string]*builder, error) {
+	outputMap := make(map[string]*builder) // mapping between function return and function
+	for _, v := range mapping {
+		for _, out := range v.Out {
+			outputMap[out] = v
+		}
+	}
+
+	available := newStringSet(initData...)
+	for _, t := range targets {
+		if _, ok := outputMap[t]; !ok && !available.Has(t) {
+			return nil, fmt.Errorf("%w: %s", ErrNoProducer, t)
+		}
+	}
+
+	selected := make(map[string]*builder)
+	queue := append([]string{}, targets...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if available.Has(name) {
+			// provided as initial data, no need to build it
+			continue
+		}
+		b, ok := outputMap[name]
+		if !ok {
+			// missing dependencies are reported when resolving the plan
+			continue
+		}
+		if _, ok := selected[b.Name]; ok {
+			continue
+		}
+		selected[b.Name] = b
+		queue = append(queue, b.In...)
+		queue = append(queue, b.Optional...)
+	}
+	return selected, nil
+}
@@ -13,22 +13,22 @@ func TestResolveDependenciesNoError(t *testing.T) {
 	deps["Name1"] = &builder{
 		Name: "Name1",
 		In:   []string{"A", "B"},
-		Out:  "C",
+		Out:  []string{"C"},
 	}
 	deps["Name2"] = &builder{
 		Name: "Name2",
 		In:   []string{"B"},
-		Out:  "A",
+		Out:  []string{"A"},
 	}
 	deps["Name3"] = &builder{
 		Name: "Name3",
 		In:   []string{},
-		Out:  "B",
+		Out:  []string{"B"},
 	}
 	deps["Name4"] = &builder{
 		Name: "Name4",
 		In:   []string{"A"},
-		Out:  "D",
+		Out:  []string{"D"},
 	}
 
 	order, err := resolveDependencies(deps)
@@ -47,27 +47,27 @@ func TestResolveParallelDependenciesNoError(t *testing.T) {
 	deps["Name1"] = &builder{
 		Name: "Name1",
 		In:   []string{"A", "B"},
-		Out:  "C",
+		Out:  []string{"C"},
 	}
 	deps["Name2"] = &builder{
 		Name: "Name2",
 		In:   []string{"B"},
-		Out:  "A",
+		Out:  []string{"A"},
 	}
 	deps["Name3"] = &builder{
 		Name: "Name3",
 		In:   []string{},
-		Out:  "B",
+		Out:  []string{"B"},
 	}
 	deps["Name4"] = &builder{
 		Name: "Name4",
 		In:   []string{"A"},
-		Out:  "D",
+		Out:  []string{"D"},
 	}
 	deps["Name5"] = &builder{
 		Name: "Name5",
 		In:   []string{"B"},
-		Out:  "F",
+		Out:  []string{"F"},
 	}
 
 	order, err := resolveDependencies(deps)
@@ -96,27 +96,27 @@ func TestResolveDependenciesDisjointGraphsNoError(t *testing.T) {
 	deps["Name1"] = &builder{
 		Name: "Name1",
 		In:   []string{"A", "B"},
-		Out:  "C",
+		Out:  []string{"C"},
 	}
 	deps["Name2"] = &builder{
 		Name: "Name2",
 		In:   []string{"B"},
-		Out:  "A",
+		Out:  []string{"A"},
 	}
 	deps["Name3"] = &builder{
 		Name: "Name3",
 		In:   []string{},
-		Out:  "B",
+		Out:  []string{"B"},
 	}
 	deps["Name4"] = &builder{
 		Name: "Name4",
 		In:   []string{},
-		Out:  "Y",
+		Out:  []string{"Y"},
 	}
 	deps["Name5"] = &builder{
 		Name: "Name5",
 		In:   []string{"Y"},
-		Out:  "Z",
+		Out:  []string{"Z"},
 	}
 
 	order, err := resolveDependencies(deps)
@@ -136,22 +136,22 @@ func TestResolveDependenciesErrorExtra(t *testing.T) {
 	deps["Name1"] = &builder{
 		Name: "Name1",
 		In:   []string{"A", "B"},
-		Out:  "C",
+		Out:  []string{"C"},
 	}
 	deps["Name2"] = &builder{
 		Name: "Name2",
 		In:   []string{"B"},
-		Out:  "A",
+		Out:  []string{"A"},
 	}
 	deps["Name3"] = &builder{
 		Name: "Name3",
 		In:   []string{},
-		Out:  "B",
+		Out:  []string{"B"},
 	}
 	deps["Name4"] = &builder{
 		Name: "Name4",
 		In:   []string{"D"},
-		Out:  "C",
+		Out:  []string{"C"},
 	}
 
 	_, err := resolveDependencies(deps)
@@ -163,24 +163,140 @@ func TestResolveDependenciesErrorCircular(t *testing.T) {
 	deps["Name1"] = &builder{
 		Name: "Name1",
 		In:   []string{"A", "B"},
-		Out:  "C",
+		Out:  []string{"C"},
 	}
 	deps["Name2"] = &builder{
 		Name: "Name2",
 		In:   []string{"B"},
-		Out:  "A",
+		Out:  []string{"A"},
 	}
 	deps["Name3"] = &builder{
 		Name: "Name3",
 		In:   []string{},
-		Out:  "B",
+		Out:  []string{"B"},
 	}
 	deps["Name4"] = &builder{
 		Name: "Name4",
 		In:   []string{"C"},
-		Out:  "A",
+		Out:  []string{"A"},
 	}
 
 	_, err := resolveDependencies(deps)
 	assert.Error(t, err)
 }
+
+func TestSelectBuilders(t *testing.T) {
+	deps := make(map[string]*builder)
+	deps["Name1"] = &builder{
+		Name: "Name1",
+		In:   []string{"A", "B"},
+		Out:  []string{"C"},
+	}
+	deps["Name2"] = &builder{
+		Name: "Name2",
+		In:   []string{"B"},
+		Out:  []string{"A"},
+	}
+	deps["Name3"] = &builder{
+		Name: "Name3",
+		In:   []string{},
+		Out:  []string{"B"},
+	}
+	deps["Name4"] = &builder{
+		Name: "Name4",
+		In:   []string{"A"},
+		Out:  []string{"D"},
+	}
+	deps["Name5"] = &builder{
+		Name: "Name5",
+		In:   []string{"Y"},
+		Out:  []string{"Z"},
+	}
+
+	selected, err := selectBuilders(deps, []string{"D"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Name2", "Name3", "Name4"}, keys(selected))
+
+	selected, err = selectBuilders(deps, []string{"D"}, "A")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Name4"}, keys(selected), "initial data should not be built")
+
+	selected, err = selectBuilders(deps, []string{"Y"}, "Y")
+	assert.NoError(t, err)
+	assert.Empty(t, selected, "targets provided as initial data need no builders")
+
+	_, err = selectBuilders(deps, []string{"X"})
+	assert.ErrorIs(t, err, ErrNoProducer)
+}
+
+func keys(m map[string]*builder) []string {
+	k := make([]string, 0, len(m))
+	for name := range m {
+		k = append(k, name)
+	}
+	return k
+}
+
+func TestResolveDependenciesOptional(t *testing.T) {
+	deps := make(map[string]*builder)
+	deps["Name1"] = &builder{
+		Name:     "Name1",
+		In:       []string{"A"},
+		Optional: []string{"B", "X"},
+		Out:      []string{"C"},
+	}
+	deps["Name2"] = &builder{
+		Name: "Name2",
+		In:   []string{"A"},
+		Out:  []string{"B"},
+	}
+
+	order, err := resolveDependencies(deps, "A")
+	assert.NoError(t, err, "optional inputs that are not produced should be ignored")
+	names := make([][]string, 0)
+	for i := range order {
+		if len(order[i]) == 0 {
+			continue
+		}
+		n := make([]string, 0)
+		for j := range order[i] {
+			n = append(n, order[i][j].Name)
+		}
+		names = append(names, n)
+	}
+	assert.Equal(t, [][]string{{"Name2"}, {"Name1"}}, names, "optional inputs that are produced should be built first")
+}
+
+func TestResolveDependenciesMultipleOutputs(t *testing.T) {
+	deps := make(map[string]*builder)
+	deps["Name1"] = &builder{
+		Name: "Name1",
+		In:   []string{},
+		Out:  []string{"A", "B"},
+	}
+	deps["Name2"] = &builder{
+		Name: "Name2",
+		In:   []string{"B"},
+		Out:  []string{"C"},
+	}
+
+	order, err := resolveDependencies(deps)
+	assert.NoError(t, err)
+	count := 0
+	for i := range order {
+		for range order[i] {
+			count += 1
+		}
+	}
+	assert.Equal(t, len(deps), count, "builders with multiple outputs should be executed once")
+
+	order, err = resolveDependencies(deps, "A")
+	assert.NoError(t, err)
+	count = 0
+	for i := range order {
+		for range order[i] {
+			count += 1
+		}
+	}
+	assert.Equal(t, len(deps), count, "builders with outputs in initial data should be executed once")
+}
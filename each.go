@@ -0,0 +1,105 @@
+package databuilder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// fanOut is the element function of a builder created with Each
+type fanOut struct {
+	// split returns the elements of the input collection
+	split func(data any) []any
+	// call calls the element function with the element in args
+	call func(ctx context.Context, args []any) ([]any, error)
+	// gather creates the output collection from the results of all elements
+	gather func(results []any) any
+}
+
+// ElementError is the error of a single element of a fan-out builder created with Each,
+// the builder fails with a *BuilderError joining the ElementError of every element that failed
+type ElementError struct {
+	// Index is the position of the element in the input collection
+	Index int
+	// Err is the error returned for the element, ErrBuilderPanic if the element function panicked
+	Err error
+	// PanicValue is the value the element function panicked with, nil if it did not panic
+	PanicValue any
+	// Stack is the stack trace of the panic, nil if the element function did not panic
+	Stack []byte
+}
+
+func (e *ElementError) Error() string {
+	if e.PanicValue != nil {
+		return fmt.Sprintf("element %d: %s: %v", e.Index, e.Err, e.PanicValue)
+	}
+	return fmt.Sprintf("element %d: %s", e.Index, e.Err)
+}
+
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
+// Each creates a fan-out builder that builds the Out collection by calling fn once for every element of the In collection,
+// the returned value can be passed to DataBuilder.AddBuilders, Configure and Plan.Replace. In and Out should be data and
+// are the only input and output of the builder
+//
+//	type CartItems []Item
+//	type ItemDetails []Detail
+//
+//	func FetchDetail(ctx context.Context, item Item) (Detail, error)
+//
+//	err := b.AddBuilders(Each[CartItems, ItemDetails](FetchDetail))
+//
+// The calls are scheduled on the workers of the plan along with the other builders, each of them is traced on its own and
+// is subject to the timeout and retry options of the builder. The results are gathered in the order of the elements, when
+// any element fails the builder fails with the ElementError of every failed element, the fallback of the builder is used
+// in place of the whole collection.
+//
+// The builder is named after fn, using fn for more than one collection fails with ErrBuilderNameConflict unless
+// the builders are named with WithName
+func Each[In ~[]E, Out ~[]D, E, D any](fn func(context.Context, E) (D, error)) any {
+	if fn == nil {
+		return nil
+	}
+	each := &fanOut{
+		split: func(data any) []any {
+			in := data.(In)
+			elements := make([]any, len(in))
+			for i, e := range in {
+				elements[i] = e
+			}
+			return elements
+		},
+		call: func(ctx context.Context, args []any) ([]any, error) {
+			d, err := fn(ctx, args[0].(E))
+			return []any{d}, err
+		},
+		gather: func(results []any) any {
+			out := make(Out, len(results))
+			for i, r := range results {
+				out[i] = r.(D)
+			}
+			return out
+		},
+	}
+	return &typedBuilder{
+		fn: fn,
+		call: func(ctx context.Context, args []any) ([]any, error) {
+			// builds the elements one after the other, plans split the calls between their workers
+			elements := each.split(args[0])
+			results := make([]any, len(elements))
+			for i, e := range elements {
+				values, err := each.call(ctx, []any{e})
+				if err != nil {
+					return nil, &ElementError{Index: i, Err: err}
+				}
+				results[i] = values[0]
+			}
+			return []any{each.gather(results)}, nil
+		},
+		ins:  []reflect.Type{reflect.TypeFor[In]()},
+		outs: []reflect.Type{reflect.TypeFor[Out]()},
+		each: each,
+	}
+}
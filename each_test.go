@@ -0,0 +1,235 @@
+package databuilder
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+type TestItems []string
+
+type TestDetails []TestStruct1
+
+type TestWishItems []string
+
+type TestWishDetails []TestStruct1
+
+func DBTestFetchDetail(_ context.Context, item string) (TestStruct1, error) {
+	return TestStruct1{Value: item}, nil
+}
+
+func TestEach(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	var running, maxRunning atomic.Int32
+	fetch := func(_ context.Context, item string) (TestStruct1, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return TestStruct1{Value: strings.ToUpper(item)}, nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Each[TestItems, TestDetails](fetch), func(details TestDetails) TestStruct2 {
+		values := make([]string, 0, len(details))
+		for _, detail := range details {
+			values = append(values, detail.Value)
+		}
+		return TestStruct2{Value: strings.Join(values, ",")}
+	})
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestItems{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 4, TestItems{"a", "b", "c", "d"})
+	assert.NoError(t, err)
+	assert.Equal(t, TestDetails{{"A"}, {"B"}, {"C"}, {"D"}}, MustGet[TestDetails](result), "results should be in the order of the elements")
+	assert.Equal(t, "A,B,C,D", MustGet[TestStruct2](result).Value)
+	assert.Greater(t, maxRunning.Load(), int32(1), "elements should be built in parallel")
+
+	result, err = executionPlan.Run(context.Background(), TestItems{})
+	assert.NoError(t, err)
+	assert.Equal(t, TestDetails{}, MustGet[TestDetails](result), "empty collections should be built")
+}
+
+func TestEachErrors(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	errItem := errors.New("bad item")
+	fetch := func(_ context.Context, item string) (TestStruct1, error) {
+		switch item {
+		case "bad":
+			return TestStruct1{}, errItem
+		case "panic":
+			panic("element panic")
+		}
+		return TestStruct1{Value: item}, nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Each[TestItems, TestDetails](fetch), func(TestDetails) TestStruct2 {
+		return TestStruct2{}
+	})
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestItems{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestItems{"a", "bad", "b", "panic"})
+	assert.ErrorIs(t, err, errItem)
+	assert.ErrorIs(t, err, ErrBuilderPanic)
+	assert.ErrorIs(t, err, ErrDependencyFailed)
+	var bErr *BuilderError
+	assert.True(t, errors.As(err, &bErr))
+	var elementErrs []*ElementError
+	for _, e := range bErr.Err.(interface{ Unwrap() []error }).Unwrap() {
+		var eErr *ElementError
+		if assert.True(t, errors.As(e, &eErr)) {
+			elementErrs = append(elementErrs, eErr)
+		}
+	}
+	if assert.Len(t, elementErrs, 2) {
+		assert.Equal(t, 1, elementErrs[0].Index)
+		assert.Equal(t, 3, elementErrs[1].Index)
+		assert.Equal(t, "element panic", elementErrs[1].PanicValue)
+	}
+	_, ok := Get[TestDetails](result)
+	assert.False(t, ok)
+
+	d = testNew(t)
+	err = d.AddBuilders(Configure(Each[TestItems, TestDetails](fetch), WithDefault(TestDetails{{"default"}})))
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestItems{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestItems{"bad"})
+	assert.NoError(t, err)
+	assert.Equal(t, TestDetails{{"default"}}, MustGet[TestDetails](result), "the fallback should replace the whole collection")
+}
+
+func TestEachOptions(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	var attempts atomic.Int32
+	fetch := func(ctx context.Context, item string) (TestStruct1, error) {
+		if item == "slow" {
+			<-ctx.Done()
+			return TestStruct1{}, ctx.Err()
+		}
+		if item == "flaky" && attempts.Add(1) == 1 {
+			return TestStruct1{}, errors.New("flaky")
+		}
+		return TestStruct1{Value: item}, nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Configure(Each[TestItems, TestDetails](fetch), WithTimeout(20*time.Millisecond), WithRetry(2, nil, nil)))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestItems{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestItems{"a", "flaky"})
+	assert.NoError(t, err, "elements should be retried on their own")
+	assert.Equal(t, TestDetails{{"a"}, {"flaky"}}, MustGet[TestDetails](result))
+
+	_, err = executionPlan.RunParallel(context.Background(), 2, TestItems{"a", "slow"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the timeout should apply to every element")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = executionPlan.Run(ctx, TestItems{"a"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.Nil(t, Each[TestItems, TestDetails](nil))
+	err = d.AddBuilders(Each[[]string, TestDetails](fetch))
+	assert.ErrorIs(t, err, ErrInvalidBuilderInput)
+
+	d = testNew(t)
+	err = d.AddBuilders(Each[TestItems, TestDetails](DBTestFetchDetail), Each[TestItems, TestDetails](DBTestFetchDetail))
+	assert.NoError(t, err, "the same builder can be added again")
+	err = d.AddBuilders(Each[TestWishItems, TestWishDetails](DBTestFetchDetail))
+	assert.ErrorIs(t, err, ErrBuilderNameConflict, "builders of other collections should not be dropped")
+	err = d.AddBuilders(Configure(Each[TestWishItems, TestWishDetails](DBTestFetchDetail), WithName("wishes")))
+	assert.NoError(t, err)
+}
+
+func TestEachDoesNotWaitForUnrelatedBuilders(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	// elements and ready builders share the workers, a builder that becomes ready while the elements
+	// are built should not wait for all of them
+	var built, builtBefore atomic.Int32
+	fetch := func(_ context.Context, item string) (TestStruct1, error) {
+		time.Sleep(20 * time.Millisecond)
+		built.Add(1)
+		return TestStruct1{Value: item}, nil
+	}
+	fast := func(s TestStruct2) TestStruct3 {
+		return TestStruct3(s)
+	}
+	dependent := func(s TestStruct3) TestStruct4 {
+		builtBefore.Store(built.Load())
+		return TestStruct4(s)
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Each[TestItems, TestDetails](fetch), fast, dependent)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestItems{}, TestStruct2{})
+	assert.NoError(t, err)
+
+	items := TestItems{"a", "b", "c", "d", "e", "f", "g", "h"}
+	result, err := executionPlan.RunParallel(context.Background(), 2, items, TestStruct2{Value: "dag"})
+	assert.NoError(t, err)
+	assert.Len(t, MustGet[TestDetails](result), len(items))
+	assert.Equal(t, "dag", MustGet[TestStruct4](result).Value)
+	assert.LessOrEqual(t, builtBefore.Load(), int32(4), "the builder should not wait for the elements")
+}
+
+func TestEachFailFast(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	errItem := errors.New("bad item")
+	fetch := func(ctx context.Context, item string) (TestStruct1, error) {
+		if item == "bad" {
+			return TestStruct1{}, errItem
+		}
+		select {
+		case <-ctx.Done():
+			return TestStruct1{}, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return TestStruct1{Value: item}, nil
+		}
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Each[TestItems, TestDetails](fetch))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestItems{})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = executionPlan.RunParallel(context.Background(), 4, TestItems{"bad", "a", "b", "c", "d"}, FailFast())
+	assert.Less(t, time.Since(start), time.Second, "the other elements should be cancelled or not started")
+	assert.ErrorIs(t, err, errItem)
+	assert.NotErrorIs(t, err, context.Canceled, "only the first error should be returned")
+	var eErr *ElementError
+	if assert.True(t, errors.As(err, &eErr)) {
+		assert.Equal(t, 0, eErr.Index)
+	}
+
+	fast := func(_ context.Context, item string) (TestStruct1, error) {
+		if item == "bad" {
+			return TestStruct1{}, errItem
+		}
+		return TestStruct1{Value: item}, nil
+	}
+	d = testNew(t)
+	err = d.AddBuilders(Configure(Each[TestItems, TestDetails](fast), WithDefault(TestDetails{{"default"}})))
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestItems{})
+	assert.NoError(t, err)
+	result, err := executionPlan.RunParallel(context.Background(), 4, TestItems{"a", "bad", "b"}, FailFast())
+	assert.NoError(t, err, "the fallback should be used before failing fast")
+	assert.Equal(t, TestDetails{{"default"}}, MustGet[TestDetails](result))
+}
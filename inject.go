@@ -0,0 +1,98 @@
+package databuilder
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// tagName is the struct tag used to declare the fields of an inputs struct
+const tagName = "databuilder"
+
+// injection describes an inputs struct, a struct parameter of a builder whose fields are tagged as inputs.
+// Every tagged field is an input of the builder, fields tagged with optional are left as the zero value when
+// the input is absent and fields of an Optional type are always optional. Untagged fields are left as the zero value
+type injection struct {
+	typ    reflect.Type
+	fields []injectedField
+}
+
+type injectedField struct {
+	param
+	index int
+}
+
+// getInjection returns the injection of t if t is an inputs struct, nil if it is not
+func getInjection(t reflect.Type) (*injection, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	var inj *injection
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+		if inj == nil {
+			inj = &injection{typ: t}
+		}
+		if !f.IsExported() {
+			return nil, fmt.Errorf("%w: field %s of %s should be exported", ErrInvalidBuilderInput, f.Name, t)
+		}
+		p, err := parseTag(f.Type, tag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %s of %s: %s", ErrInvalidBuilderInput, f.Name, t, err)
+		}
+		inj.fields = append(inj.fields, injectedField{param: p, index: i})
+	}
+	return inj, nil
+}
+
+// parseTag returns the param filling a field of type t tagged with tag
+func parseTag(t reflect.Type, tag string) (param, error) {
+	parts := strings.Split(tag, ",")
+	if parts[0] != "in" {
+		return param{}, fmt.Errorf("tag should start with in, got %q", tag)
+	}
+	data := t
+	if o := getOptionalOf(t); o != nil {
+		data = o
+	}
+	if !isInputType(data) {
+		return param{}, fmt.Errorf("type %s is not data", data)
+	}
+	p := newParam(t)
+	for _, opt := range parts[1:] {
+		switch {
+		case opt == "optional":
+			p.zeroIfAbsent = true
+		case strings.HasPrefix(opt, "name="):
+			qualifier := strings.TrimPrefix(opt, "name=")
+			if qualifier == "" {
+				return param{}, fmt.Errorf("name should not be empty")
+			}
+			p.name = qualifiedName(p.name, qualifier)
+		default:
+			return param{}, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	return p, nil
+}
+
+// build creates the inputs struct from the data, it reports false if a required input is missing
+func (inj *injection) build(data map[string]any) (any, bool) {
+	v := reflect.New(inj.typ).Elem()
+	for _, f := range inj.fields {
+		value, ok := data[f.name]
+		switch {
+		case f.optional != nil:
+			v.Field(f.index).Set(reflect.ValueOf(newOptional(f.optional, value, ok)))
+		case ok:
+			v.Field(f.index).Set(reflect.ValueOf(value))
+		case !f.zeroIfAbsent:
+			return nil, false
+		}
+	}
+	return v.Interface(), true
+}
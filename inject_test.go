@@ -0,0 +1,88 @@
+package databuilder
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type TestInputs struct {
+	S1       TestStruct1           `databuilder:"in"`
+	S2       TestStruct2           `databuilder:"in,optional"`
+	Billing  TestStruct2           `databuilder:"in,name=billing"`
+	S3       Optional[TestStruct3] `databuilder:"in"`
+	S4       *TestStruct4          `databuilder:"in,optional"`
+	Untagged TestStruct5
+}
+
+func DBTestFuncInputs(_ context.Context, in TestInputs) (TestStruct5, error) {
+	return TestStruct5{
+		Value: in.S1.Value + "|" + in.S2.Value + "|" + in.Billing.Value + "|" + in.S3.Value.Value,
+	}, nil
+}
+
+func TestInputsStruct(t *testing.T) {
+	assert.NoError(t, IsValidBuilder(DBTestFuncInputs))
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFuncInputs, DBTestFunc4, Named("billing", DBTestFunc5))
+	assert.NoError(t, err)
+	b := d.builders["github.com/go-coldbrew/data-builder.DBTestFuncInputs"]
+	assert.ElementsMatch(t, []string{
+		getStructName(reflect.TypeFor[TestStruct1]()),
+		qualifiedName(getStructName(reflect.TypeFor[TestStruct2]()), "billing"),
+	}, b.In)
+	assert.ElementsMatch(t, []string{
+		getStructName(reflect.TypeFor[TestStruct2]()),
+		getStructName(reflect.TypeFor[TestStruct3]()),
+		getStructName(reflect.TypeFor[*TestStruct4]()),
+	}, b.Optional)
+
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a-b||a--b|a-b", MustGet[TestStruct5](result).Value, "absent optional fields should be the zero value")
+
+	_, err = d.CompileFor([]any{TestStruct5{}}, TestStruct2{})
+	assert.ErrorIs(t, err, ErrCouldNotResolveDependency, "required fields should be resolved")
+}
+
+func TestInputsStructQualified(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(
+		Configure(DBTestFuncInputs, WithQualifiedInput(TestStruct2{}, "other")),
+		DBTestFunc,
+		Named("billing", DBTestFunc5),
+	)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a-b||a--b|", MustGet[TestStruct5](result).Value, "qualified fields should not be filled with unqualified data")
+}
+
+func TestInputsStructInvalid(t *testing.T) {
+	type unexported struct {
+		s1 TestStruct1 `databuilder:"in"`
+	}
+	type badTag struct {
+		S1 TestStruct1 `databuilder:"out"`
+	}
+	type badOption struct {
+		S1 TestStruct1 `databuilder:"in,maybe"`
+	}
+	type notData struct {
+		S1 int `databuilder:"in"`
+	}
+	type sameAsOutput struct {
+		S2 TestStruct2 `databuilder:"in"`
+	}
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, unexported) (TestStruct2, error) { return TestStruct2{}, nil }), ErrInvalidBuilderInput)
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, badTag) (TestStruct2, error) { return TestStruct2{}, nil }), ErrInvalidBuilderInput)
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, badOption) (TestStruct2, error) { return TestStruct2{}, nil }), ErrInvalidBuilderInput)
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, notData) (TestStruct2, error) { return TestStruct2{}, nil }), ErrInvalidBuilderInput)
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, sameAsOutput) (TestStruct2, error) { return TestStruct2{}, nil }), ErrSameInputAsOutput)
+}
@@ -0,0 +1,120 @@
+package databuilder
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// isInterfaceInput checks if t is an interface builders can depend on, the interface should be named and have methods
+func isInterfaceInput(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.PkgPath() != "" && t.NumMethod() > 0
+}
+
+// isInputType checks if values of type t can be used as inputs of builders
+func isInputType(t reflect.Type) bool {
+	return isDataType(t) || isInterfaceInput(t)
+}
+
+// getDataTypes returns the types of the data provided to Compile, qualified data is skipped
+func getDataTypes(data []any) map[string]reflect.Type {
+	dataTypes := make(map[string]reflect.Type, len(data))
+	for _, inter := range data {
+		if inter == nil {
+			continue
+		}
+		if _, ok := inter.(*namedValue); ok {
+			continue
+		}
+		t := reflect.TypeOf(inter)
+		dataTypes[getStructName(t)] = t
+	}
+	return dataTypes
+}
+
+// bindInterfaces chooses the data that feeds each interface input of the builders, the data should be
+// the only output of the builders or initial data that implements the interface.
+// It returns the builders with their interface inputs replaced by the chosen data, the bindings used and the
+// interfaces implemented by more than one data mapped to the error describing it, their inputs stay unbound
+// and are only reported when a builder depending on them is needed
+func bindInterfaces(builders map[string]*builder, initData map[string]reflect.Type) (map[string]*builder, map[string]string, map[string]error) {
+	interfaces := make(map[string]reflect.Type)
+	for _, b := range builders {
+		for _, p := range b.inputs() {
+			if p.iface != nil {
+				interfaces[p.name] = p.iface
+			}
+		}
+	}
+	if len(interfaces) == 0 {
+		return builders, nil, nil
+	}
+
+	available := make(map[string]reflect.Type, len(initData))
+	for name, t := range initData {
+		available[name] = t
+	}
+	for _, b := range builders {
+		for i, t := range b.outTypes {
+			available[b.Out[i]] = t
+		}
+	}
+	bindings := make(map[string]string, len(interfaces))
+	ambiguous := make(map[string]error)
+	for name, iface := range interfaces {
+		var candidates []string
+		for data, t := range available {
+			if t.Implements(iface) {
+				candidates = append(candidates, data)
+			}
+		}
+		switch len(candidates) {
+		case 0:
+			// the input stays unresolved, resolving dependencies reports it
+		case 1:
+			bindings[name] = candidates[0]
+		default:
+			slices.Sort(candidates)
+			ambiguous[name] = fmt.Errorf("%w: %s is implemented by %v", ErrAmbiguousDependency, name, candidates)
+		}
+	}
+
+	bound := make(map[string]*builder, len(builders))
+	for name, b := range builders {
+		bound[name] = b.bind(bindings)
+	}
+	return bound, bindings, ambiguous
+}
+
+// bind returns the builder with its interface inputs replaced by the data in bindings,
+// the builder itself is returned when it has no interface inputs
+func (b *builder) bind(bindings map[string]string) *builder {
+	if !slices.ContainsFunc(b.inputs(), func(p *param) bool { return p.iface != nil && bindings[p.name] != "" }) {
+		return b
+	}
+	c := *b
+	c.params = slices.Clone(b.params)
+	for i := range c.params {
+		if inj := c.params[i].inject; inj != nil {
+			c.params[i].inject = &injection{typ: inj.typ, fields: slices.Clone(inj.fields)}
+		}
+	}
+	for _, p := range c.inputs() {
+		if data, ok := bindings[p.name]; ok && p.iface != nil {
+			p.name = data
+		}
+	}
+	c.setInputs()
+	if len(c.variants) > 0 {
+		c.variants = slices.Clone(b.variants)
+		for i := range c.variants {
+			c.variants[i].builder = c.variants[i].bind(bindings)
+		}
+	}
+	if c.shadow != nil {
+		sh := *b.shadow
+		sh.candidate = sh.candidate.bind(bindings)
+		c.shadow = &sh
+	}
+	return &c
+}
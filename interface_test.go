@@ -0,0 +1,102 @@
+package databuilder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type TestValuer interface {
+	GetValue() string
+}
+
+func (s TestStruct2) GetValue() string {
+	return "2:" + s.Value
+}
+
+func (s *TestStruct3) GetValue() string {
+	return "3:" + s.Value
+}
+
+func DBTestFuncInterface(_ context.Context, v TestValuer) (TestStruct4, error) {
+	return TestStruct4{Value: v.GetValue()}, nil
+}
+
+func DBTestFuncOptionalInterface(_ context.Context, _ TestStruct1, v Optional[TestValuer]) (TestStruct5, error) {
+	if !v.Valid {
+		return TestStruct5{Value: "none"}, nil
+	}
+	return TestStruct5{Value: v.Value.GetValue()}, nil
+}
+
+func DBTestFuncPointer3(_ context.Context, s TestStruct1) (*TestStruct3, error) {
+	return &TestStruct3{Value: s.Value}, nil
+}
+
+func TestInterfaceInput(t *testing.T) {
+	assert.NoError(t, IsValidBuilder(DBTestFuncInterface))
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, any) (TestStruct1, error) { return TestStruct1{}, nil }), ErrInvalidBuilderInput, "empty interfaces can not be satisfied")
+	assert.ErrorIs(t, IsValidBuilder(func(context.Context, TestStruct1) (TestValuer, error) { return nil, nil }), ErrInvalidBuilderFirstOutput, "outputs should be concrete")
+
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFunc, DBTestFuncInterface, DBTestFuncOptionalInterface)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2:a_b", MustGet[TestStruct4](result).Value)
+	assert.Equal(t, "2:a_b", MustGet[TestStruct5](result).Value)
+
+	err = executionPlan.Replace(context.Background(), DBTestFuncInterface, func(_ context.Context, v TestValuer) (TestStruct4, error) {
+		return TestStruct4{Value: "replaced " + v.GetValue()}, nil
+	})
+	assert.NoError(t, err, "replacement should be bound to the same data")
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "replaced 2:a_b", MustGet[TestStruct4](result).Value)
+
+	// T does not implement the interface when methods have a pointer receiver
+	d = testNew(t)
+	err = d.AddBuilders(DBTestFunc4, DBTestFuncOptionalInterface)
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "none", MustGet[TestStruct5](result).Value)
+
+	_, err = d.Compile(TestStruct1{}, &TestStruct3{})
+	assert.NoError(t, err, "initial data can implement interfaces")
+	err = d.AddBuilders(DBTestFuncInterface)
+	assert.NoError(t, err)
+	_, err = d.Compile(TestStruct1{})
+	assert.ErrorIs(t, err, ErrCouldNotResolveDependency)
+}
+
+func TestInterfaceInputAmbiguous(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFunc, DBTestFuncPointer3, DBTestFuncInterface)
+	assert.NoError(t, err)
+	_, err = d.Compile(TestStruct1{})
+	assert.ErrorIs(t, err, ErrAmbiguousDependency)
+	_, err = d.CompileFor([]any{TestStruct4{}}, TestStruct1{})
+	assert.ErrorIs(t, err, ErrAmbiguousDependency)
+	executionPlan, err := d.CompileFor([]any{TestStruct2{}}, TestStruct1{})
+	assert.NoError(t, err, "ambiguity is only reported when a builder depending on the interface is needed")
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a_b", MustGet[TestStruct2](result).Value)
+
+	d = testNew(t)
+	err = d.AddBuilders(DBTestFuncPointer3, DBTestFuncInterface)
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "3:a", MustGet[TestStruct4](result).Value)
+	_, err = d.Compile(TestStruct1{}, TestStruct2{})
+	assert.ErrorIs(t, err, ErrAmbiguousDependency)
+}
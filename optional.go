@@ -0,0 +1,62 @@
+package databuilder
+
+import "reflect"
+
+// Optional declares an optional input of a builder, use it as the type of a builder parameter
+//
+//	func Builder(ctx context.Context, req AppRequest, loc Optional[Location]) (AppResponse, error)
+//
+// when T is produced by another builder in the plan, the builder is executed after it and Valid is true
+// if it was built successfully. When T is not produced or its builder failed, the builder still runs
+// with Valid set to false
+type Optional[T any] struct {
+	// Value is the value of the input, the zero value of T when the input is absent
+	Value T
+	// Valid reports whether the input is present
+	Valid bool
+}
+
+// Get returns the value of the input and reports whether it is present
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Valid
+}
+
+func (o Optional[T]) optionalOf() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+func (o Optional[T]) with(data any) any {
+	if v, ok := data.(T); ok {
+		return Optional[T]{Value: v, Valid: true}
+	}
+	return Optional[T]{}
+}
+
+// optionalInput is implemented by all Optional types
+type optionalInput interface {
+	optionalOf() reflect.Type
+	// with returns the Optional holding data, or an absent Optional if data is not of the wrapped type
+	with(data any) any
+}
+
+var optionalInputType = reflect.TypeFor[optionalInput]()
+
+// getOptionalOf returns the type wrapped by t if t is an Optional type, nil otherwise
+func getOptionalOf(t reflect.Type) reflect.Type {
+	if !t.Implements(optionalInputType) {
+		return nil
+	}
+	o, ok := reflect.Zero(t).Interface().(optionalInput)
+	if !ok {
+		return nil
+	}
+	return o.optionalOf()
+}
+
+// newOptional creates a value of the Optional type of o, the value is valid when present is true
+func newOptional(o optionalInput, data any, present bool) any {
+	if !present {
+		return o
+	}
+	return o.with(data)
+}
@@ -0,0 +1,87 @@
+package databuilder
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func DBTestFuncOptional(_ context.Context, s TestStruct1, o Optional[TestStruct2]) (TestStruct3, error) {
+	if v, ok := o.Get(); ok {
+		return TestStruct3{Value: s.Value + "+" + v.Value}, nil
+	}
+	return TestStruct3{Value: s.Value}, nil
+}
+
+func DBTestFuncSlow(_ context.Context, s TestStruct1) (TestStruct2, error) {
+	time.Sleep(10 * time.Millisecond)
+	return TestStruct2{Value: "slow"}, nil
+}
+
+func TestOptionalInputPresent(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFuncOptional, DBTestFuncSlow)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestStruct1{Value: "in"})
+	assert.NoError(t, err)
+	assert.Equal(t, "in+slow", MustGet[TestStruct3](result).Value, "builder should run after the optional input is built")
+	goleak.VerifyNone(t)
+}
+
+func TestOptionalInputNotProduced(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFuncOptional)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err, "optional inputs should not be required")
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "in"})
+	assert.NoError(t, err)
+	assert.Equal(t, "in", MustGet[TestStruct3](result).Value)
+
+	// optional inputs can be provided as initial data
+	executionPlan, err = d.Compile(TestStruct1{}, TestStruct2{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "in"}, TestStruct2{Value: "init"})
+	assert.NoError(t, err)
+	assert.Equal(t, "in+init", MustGet[TestStruct3](result).Value)
+	goleak.VerifyNone(t)
+}
+
+func TestOptionalInputFailed(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFuncOptional, DBTestFuncErr)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "in"})
+	assert.ErrorContains(t, err, "DBTestFunc encountered an error")
+	assert.NotErrorIs(t, err, ErrDependencyFailed, "builders with optional inputs should not be skipped")
+	assert.Equal(t, "in", MustGet[TestStruct3](result).Value)
+	goleak.VerifyNone(t)
+}
+
+func TestOptionalInputValidation(t *testing.T) {
+	invalid := func(_ context.Context, _ Optional[int]) (TestStruct1, error) {
+		return TestStruct1{}, nil
+	}
+	same := func(_ context.Context, _ Optional[TestStruct1]) (TestStruct1, error) {
+		return TestStruct1{}, nil
+	}
+	assert.NoError(t, IsValidBuilder(DBTestFuncOptional))
+	assert.ErrorIs(t, IsValidBuilder(invalid), ErrInvalidBuilderInput)
+	assert.ErrorIs(t, IsValidBuilder(same), ErrSameInputAsOutput)
+
+	b, err := getBuilder(DBTestFuncOptional)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{getStructName(reflect.TypeFor[TestStruct1]())}, b.In)
+	assert.Equal(t, []string{getStructName(reflect.TypeFor[TestStruct2]())}, b.Optional)
+}
@@ -0,0 +1,254 @@
+package databuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidOption is returned when an option is given an invalid value
+var ErrInvalidOption = errors.New("invalid option")
+
+// BuilderOption configures how a single builder is executed
+type BuilderOption func(*builder) error
+
+// builderConfig holds the execution settings of a builder
+type builderConfig struct {
+	timeout  time.Duration
+	retry    *retryPolicy
+	fallback func(ctx context.Context, err error) ([]any, error)
+}
+
+// builderSpec is a builder function along with the options it is registered with
+type builderSpec struct {
+	fn   any
+	opts []BuilderOption
+}
+
+// Configure attaches options to a builder function, the returned value can be passed to
+// DataBuilder.AddBuilders and Plan.Replace in place of the builder function
+func Configure(fn any, opts ...BuilderOption) any {
+	if s, ok := fn.(*builderSpec); ok {
+		// keep the options the function was already configured with
+		return &builderSpec{fn: s.fn, opts: append(slices.Clone(s.opts), opts...)}
+	}
+	return &builderSpec{fn: fn, opts: opts}
+}
+
+// WithName sets the name the builder is identified with, the name is used in tracing spans, errors,
+// the dependency graph and to replace the builder with Plan.Replace.
+//
+// builders are named after their function by default, closures created by the same function and
+// method values of the same method share that name, so they should be given a name of their own
+func WithName(name string) BuilderOption {
+	return func(b *builder) error {
+		if name == "" {
+			return fmt.Errorf("%w: name should not be empty", ErrInvalidOption)
+		}
+		b.Name = name
+		b.named = true
+		return nil
+	}
+}
+
+// WithTimeout sets the maximum duration the builder is allowed to run, the context passed to
+// the builder is cancelled once the timeout expires and the builder fails with context.DeadlineExceeded
+//
+// builders are expected to honour context cancellation, a builder that ignores its context
+// can not be stopped
+func WithTimeout(d time.Duration) BuilderOption {
+	return func(b *builder) error {
+		if d <= 0 {
+			return ErrInvalidOption
+		}
+		b.timeout = d
+		return nil
+	}
+}
+
+// Backoff returns the duration to wait before retrying a builder, attempt is the number of attempts made so far
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff waits the same duration before every retry
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff doubles the wait before every retry starting from base, the wait never exceeds maxWait
+func ExponentialBackoff(base, maxWait time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < maxWait; i++ {
+			d *= 2
+		}
+		return min(d, maxWait)
+	}
+}
+
+// WithRetry calls the builder up to maxAttempts times until it succeeds, waiting for backoff between attempts.
+// Only errors for which retryIf returns true are retried, all errors are retried when retryIf is nil.
+// Panics are never retried and a timeout set with WithTimeout applies to every attempt.
+//
+// the number of attempts made is recorded on the tracing span of the builder
+func WithRetry(maxAttempts int, backoff Backoff, retryIf func(error) bool) BuilderOption {
+	return func(b *builder) error {
+		if maxAttempts < 1 {
+			return ErrInvalidOption
+		}
+		b.retry = &retryPolicy{
+			maxAttempts: maxAttempts,
+			backoff:     backoff,
+			retryIf:     retryIf,
+		}
+		return nil
+	}
+}
+
+type retryPolicy struct {
+	maxAttempts int
+	backoff     Backoff
+	retryIf     func(error) bool
+}
+
+// shouldRetry reports whether the builder should be called again after failing with err
+func (r *retryPolicy) shouldRetry(ctx context.Context, attempts int, err error) bool {
+	if r == nil || attempts >= r.maxAttempts || ctx.Err() != nil {
+		return false
+	}
+	return r.retryIf == nil || r.retryIf(err)
+}
+
+// wait waits for the backoff duration, it returns false if the context is done before that
+func (r *retryPolicy) wait(ctx context.Context, attempts int) bool {
+	if r.backoff == nil {
+		return ctx.Err() == nil
+	}
+	d := r.backoff(attempts)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// WithFallback sets a function that is called when the builder fails or panics, the values returned by
+// the fallback are used as the outputs of the builder so its dependents still run. fallback should be a
+// function of the form
+//
+//	func(ctx context.Context, err error) (Output, error)
+//
+// where Output are the outputs of the builder, the error passed is the *BuilderError of the builder.
+// When the fallback succeeds the error of the builder is reported in the Warnings of the Result
+// instead of the error of the plan
+func WithFallback(fallback any) BuilderOption {
+	return func(b *builder) error {
+		t := reflect.TypeOf(fallback)
+		valid := t != nil && t.Kind() == reflect.Func && !reflect.ValueOf(fallback).IsNil() &&
+			t.NumIn() == 2 && t.In(0) == contextType && t.In(1) == errorType &&
+			t.NumOut() == len(b.outTypes)+1 && t.Out(t.NumOut()-1) == errorType
+		for i := 0; valid && i < len(b.outTypes); i++ {
+			valid = t.Out(i) == b.outTypes[i]
+		}
+		if !valid {
+			return fmt.Errorf("%w: fallback should be func(context.Context, error) (%s, error)", ErrInvalidOption, describeOutputs(b.outTypes))
+		}
+		fn := reflect.ValueOf(fallback)
+		b.fallback = func(ctx context.Context, err error) ([]any, error) {
+			outputs := fn.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(&err).Elem()})
+			last := outputs[len(outputs)-1]
+			if !last.IsNil() {
+				err, _ := last.Interface().(error)
+				return nil, err
+			}
+			values := make([]any, 0, len(outputs)-1)
+			for _, v := range outputs[:len(outputs)-1] {
+				values = append(values, v.Interface())
+			}
+			return values, nil
+		}
+		return nil
+	}
+}
+
+// WithDefault sets values that are used as the outputs of the builder when it fails or panics,
+// it works the same way as WithFallback with a fallback that always returns values
+func WithDefault(values ...any) BuilderOption {
+	return func(b *builder) error {
+		if len(values) != len(b.outTypes) {
+			return fmt.Errorf("%w: default should be (%s)", ErrInvalidOption, describeOutputs(b.outTypes))
+		}
+		for i, value := range values {
+			if reflect.TypeOf(value) != b.outTypes[i] {
+				return fmt.Errorf("%w: default should be (%s)", ErrInvalidOption, describeOutputs(b.outTypes))
+			}
+			if isNilPointer(value) {
+				return fmt.Errorf("%w: default should not be a nil pointer", ErrInvalidOption)
+			}
+		}
+		defaults := slices.Clone(values)
+		b.fallback = func(context.Context, error) ([]any, error) {
+			return defaults, nil
+		}
+		return nil
+	}
+}
+
+// describeOutputs returns the types of the outputs for use in error messages
+func describeOutputs(outs []reflect.Type) string {
+	outputs := make([]string, 0, len(outs))
+	for _, out := range outs {
+		outputs = append(outputs, out.String())
+	}
+	return strings.Join(outputs, ", ")
+}
+
+// Option configures the DataBuilder and the plans compiled from it
+type Option func(*options)
+
+type options struct {
+	defaultTimeout time.Duration
+	planTimeout    time.Duration
+}
+
+// WithDefaultTimeout sets the timeout of builders that are not registered with their own WithTimeout
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.defaultTimeout = d
+	}
+}
+
+// WithPlanTimeout sets the deadline budget for a complete plan run, builders that are still
+// running when the budget is exhausted are cancelled and builders not yet started are not run
+func WithPlanTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.planTimeout = d
+	}
+}
+
+// RunOption configures a single run of a plan, run options are passed to Plan.Run and Plan.RunParallel
+// along with the initial data
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	failFast bool
+}
+
+// FailFast stops the run as soon as a builder fails, the context of all in flight builders is cancelled,
+// no other builder is started and only the first error is returned
+func FailFast() RunOption {
+	return func(o *runOptions) {
+		o.failFast = true
+	}
+}
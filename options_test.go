@@ -0,0 +1,289 @@
+package databuilder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func DBTestFuncBlocking(ctx context.Context, s TestStruct1) (TestStruct2, error) {
+	<-ctx.Done()
+	return TestStruct2(s), ctx.Err()
+}
+
+func TestWithTimeout(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(
+		Configure(DBTestFuncBlocking, WithTimeout(10*time.Millisecond)),
+		DBTestFunc4,
+		DBTestFunc7,
+	)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestStruct1{Value: "test"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var bErr *BuilderError
+	if assert.ErrorAs(t, err, &bErr) {
+		assert.Contains(t, bErr.Builder, "DBTestFuncBlocking")
+	}
+	_, ok := Get[TestStruct4](result)
+	assert.True(t, ok, "independent builders should complete")
+	goleak.VerifyNone(t)
+}
+
+func TestWithTimeoutWrapsBuilderError(t *testing.T) {
+	ignoresCause := func(ctx context.Context, _ TestStruct1) (TestStruct2, error) {
+		<-ctx.Done()
+		return TestStruct2{}, errors.New("downstream call failed")
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Configure(ignoresCause, WithTimeout(10*time.Millisecond)))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "downstream call failed")
+}
+
+func TestWithTimeoutInvalid(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(Configure(DBTestFunc, WithTimeout(0)))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorIs(t, IsValidBuilder(Configure(DBTestFuncInvalid1)), ErrInvalidBuilderInput)
+	assert.NoError(t, IsValidBuilder(Configure(DBTestFunc)))
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	d := New(WithDefaultTimeout(10 * time.Millisecond))
+	err := d.AddBuilders(DBTestFuncBlocking)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	goleak.VerifyNone(t)
+}
+
+func TestWithPlanTimeout(t *testing.T) {
+	d := New(WithPlanTimeout(10 * time.Millisecond))
+	err := d.AddBuilders(DBTestFuncBlocking, DBTestFuncAfterErr)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	goleak.VerifyNone(t)
+}
+
+func TestReplaceKeepsOptions(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(Configure(DBTestFunc, WithTimeout(10*time.Millisecond)))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	err = executionPlan.Replace(context.Background(), DBTestFunc, DBTestFuncBlocking)
+	assert.NoError(t, err)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "replaced builder should keep the timeout")
+	goleak.VerifyNone(t)
+}
+
+func TestWithRetry(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	attempts := 0
+	flaky := func(_ context.Context, s TestStruct1) (TestStruct2, error) {
+		attempts++
+		if attempts < 3 {
+			return TestStruct2{}, errFlaky
+		}
+		return TestStruct2(s), nil
+	}
+
+	d := testNew(t)
+	err := d.AddBuilders(Configure(flaky, WithRetry(3, ConstantBackoff(time.Millisecond), nil)))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "retry"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, "retry", MustGet[TestStruct2](result).Value)
+
+	// attempts exhausted
+	failed := 0
+	failing := func(_ context.Context, _ TestStruct1) (TestStruct3, error) {
+		failed++
+		return TestStruct3{}, errFlaky
+	}
+	d = testNew(t)
+	err = d.AddBuilders(Configure(failing, WithRetry(3, nil, nil)))
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, errFlaky)
+	assert.Equal(t, 3, failed)
+
+	// errors not matching retryIf are not retried
+	d = testNew(t)
+	err = d.AddBuilders(Configure(flaky, WithRetry(3, nil, func(err error) bool {
+		return !errors.Is(err, errFlaky)
+	})))
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	attempts = 0
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, errFlaky)
+	assert.Equal(t, 1, attempts)
+	goleak.VerifyNone(t)
+}
+
+func TestWithRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	failing := func(_ context.Context, _ TestStruct1) (TestStruct2, error) {
+		attempts++
+		cancel()
+		return TestStruct2{}, errors.New("failed")
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Configure(failing, WithRetry(5, ConstantBackoff(time.Hour), nil)))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = executionPlan.Run(ctx, TestStruct1{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts, "cancelled context should not be retried")
+
+	assert.ErrorIs(t, d.AddBuilders(Configure(DBTestFunc, WithRetry(0, nil, nil))), ErrInvalidOption)
+	goleak.VerifyNone(t)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, b(1))
+	assert.Equal(t, 20*time.Millisecond, b(2))
+	assert.Equal(t, 40*time.Millisecond, b(3))
+	assert.Equal(t, 50*time.Millisecond, b(4))
+	assert.Equal(t, 50*time.Millisecond, b(100))
+}
+
+func TestWithFallback(t *testing.T) {
+	var fallbackErr error
+	fallback := func(_ context.Context, err error) (TestStruct2, error) {
+		fallbackErr = err
+		return TestStruct2{Value: "fallback"}, nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Configure(DBTestFuncErr, WithFallback(fallback)), DBTestFuncAfterErr)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "test"})
+	assert.NoError(t, err, "builder errors should be reported as warnings")
+	assert.Equal(t, "fallback", MustGet[TestStruct5](result).Value, "dependents should run on the fallback value")
+	warnings, ok := Get[Warnings](result)
+	if assert.True(t, ok) && assert.Len(t, warnings.Errors, 1) {
+		var bErr *BuilderError
+		assert.ErrorAs(t, warnings.Errors[0], &bErr)
+		assert.ErrorContains(t, bErr, "DBTestFunc encountered an error")
+	}
+	assert.ErrorContains(t, fallbackErr, "DBTestFunc encountered an error")
+	goleak.VerifyNone(t)
+}
+
+func TestWithFallbackFails(t *testing.T) {
+	fallback := func(_ context.Context, err error) (TestStruct2, error) {
+		return TestStruct2{}, errors.New("fallback failed too")
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Configure(DBTestFuncErr, WithFallback(fallback)), DBTestFuncAfterErr)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorContains(t, err, "DBTestFunc encountered an error")
+	assert.ErrorContains(t, err, "fallback failed too")
+	assert.ErrorIs(t, err, ErrDependencyFailed)
+	_, ok := Get[Warnings](result)
+	assert.False(t, ok)
+	goleak.VerifyNone(t)
+}
+
+func TestWithDefault(t *testing.T) {
+	panics := func(_ context.Context, _ TestStruct1) (TestStruct2, error) {
+		panic("boom")
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Configure(panics, WithDefault(TestStruct2{Value: "default"})), DBTestFuncAfterErr)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{})
+	assert.NoError(t, err)
+	assert.Equal(t, "default", MustGet[TestStruct5](result).Value)
+	warnings := MustGet[Warnings](result)
+	assert.ErrorIs(t, warnings.Errors[0], ErrBuilderPanic)
+	goleak.VerifyNone(t)
+}
+
+func TestWithFallbackInvalid(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(Configure(DBTestFunc, WithDefault(TestStruct1{})))
+	assert.ErrorIs(t, err, ErrInvalidOption, "default should match the output")
+	err = d.AddBuilders(Configure(DBTestFunc, WithFallback(func(context.Context, error) (TestStruct1, error) {
+		return TestStruct1{}, nil
+	})))
+	assert.ErrorIs(t, err, ErrInvalidOption, "fallback should match the output")
+	err = d.AddBuilders(Configure(DBTestFunc, WithFallback(DBTestFunc2)))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	err = d.AddBuilders(Configure(DBTestFunc, WithFallback(nil)))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+}
+
+func TestFailFast(t *testing.T) {
+	errFailed := errors.New("failed")
+	failing := func(_ context.Context, _ TestStruct1) (TestStruct2, error) {
+		time.Sleep(10 * time.Millisecond)
+		return TestStruct2{}, errFailed
+	}
+	started := false
+	notStarted := func(_ context.Context, s TestStruct3) (TestStruct4, error) {
+		started = true
+		return TestStruct4(s), nil
+	}
+
+	blocking := func(ctx context.Context, s TestStruct1) (TestStruct3, error) {
+		<-ctx.Done()
+		return TestStruct3(s), ctx.Err()
+	}
+
+	d := testNew(t)
+	err := d.AddBuilders(failing, blocking, notStarted)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = executionPlan.RunParallel(context.Background(), 2, TestStruct1{}, FailFast())
+	assert.ErrorIs(t, err, errFailed)
+	assert.NotErrorIs(t, err, context.Canceled, "only the first error should be returned")
+	assert.False(t, started, "no builder should be started after the first error")
+	goleak.VerifyNone(t)
+}
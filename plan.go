@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"runtime/debug"
+	"slices"
 	"strconv"
-	"sync"
+	"time"
 
 	"github.com/go-coldbrew/tracing"
 	graphviz "github.com/goccy/go-graphviz"
@@ -20,13 +23,19 @@ var ErrWTF = errors.New("what a terrible failure: this is likely a bug in depend
 
 type plan struct {
 	order    [][]*builder
-	initData stringSet // the initial data required for this plan
+	initData stringSet         // the initial data required for this plan
+	bindings map[string]string // mapping between interface inputs and the data implementing them
+	opts     options
 }
 
 func (p *plan) Replace(ctx context.Context, from any, to any) error {
-	f, err := getBuilder(from)
-	if err != nil {
-		return err
+	name, ok := from.(string)
+	if !ok {
+		f, err := getBuilder(from)
+		if err != nil {
+			return err
+		}
+		name = f.Name
 	}
 
 	t, err := getBuilder(to)
@@ -34,31 +43,39 @@ func (p *plan) Replace(ctx context.Context, from any, to any) error {
 		return err
 	}
 
-	if f.Name == t.Name {
-		// same function, do nothing
-		return nil
-	}
-
-	if f.Out != t.Out {
-		return errors.New("both builders should have the same output")
-	}
-
-	input := newStringSet(f.In...)
-	if !input.IsSuperset(newStringSet(t.In...)) {
-		return errors.New("replace can NOT introduce dependencies, please compile a new plan")
-	}
-
 	for i := range p.order {
 		for j := range p.order[i] {
 			b := p.order[i][j]
-			if f.Name == b.Name {
-				// same function, lets replace it
-				p.order[i][j] = t
-				return nil
+			if name != b.Name {
+				continue
 			}
+			if _, ok := to.(*builderSpec); !ok {
+				// keep the options the builder was registered with
+				t, err = getBuilder(Configure(to, b.opts...))
+				if err != nil {
+					return err
+				}
+			}
+			// interface inputs are fed by the same data as in the rest of the plan
+			// and contributions are collected as the contributions of the replaced builder
+			t = t.bind(p.bindings).contributes(b.Name)
+
+			if !slices.Equal(b.Out, t.Out) {
+				return errors.New("both builders should have the same output")
+			}
+
+			input := newStringSet(b.In...)
+			input.Insert(b.Optional...)
+			if !input.IsSuperset(newStringSet(t.In...)) || !input.IsSuperset(newStringSet(t.Optional...)) {
+				return errors.New("replace can NOT introduce dependencies, please compile a new plan")
+			}
+
+			// same function, lets replace it
+			p.order[i][j] = t
+			return nil
 		}
 	}
-	return errors.New("builder not found")
+	return fmt.Errorf("builder %s not found", name)
 }
 
 func (p *plan) Run(ctx context.Context, initData ...any) (Result, error) {
@@ -73,39 +90,128 @@ func (p *plan) RunParallel(ctx context.Context, workers uint, initData ...any) (
 	defer span.End()
 	dataMap := make(map[string]any)
 	initialData := newStringSet()
+	var opts runOptions
 	for _, inter := range initData {
 		if inter == nil {
 			continue
 		}
-		t := reflect.TypeOf(inter)
-		if t.Kind() != reflect.Struct {
-			return nil, ErrInvalidBuilderInput
+		if opt, ok := inter.(RunOption); ok {
+			if opt != nil {
+				opt(&opts)
+			}
+			continue
+		}
+		name, value, err := getData(inter)
+		if err != nil {
+			return nil, err
+		}
+		if isNilPointer(value) {
+			// nil pointers are ignored the same way as nil values
+			continue
 		}
-		name := getStructName(t)
 		if initialData.Has(name) {
 			return nil, ErrMultipleInitialData
 		}
 		initialData.Insert(name)
-		dataMap[name] = inter
+		dataMap[name] = value
 	}
 	span.SetTag("workers", workers)
 	if p.initData.Difference(initialData).Len() > 0 {
 		return nil, span.SetError(ErrInitialDataMissing)
 	}
-	return dataMap, span.SetError(p.run(ctx, workers, dataMap))
+	if p.opts.planTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.planTimeout)
+		defer cancel()
+	}
+	return dataMap, span.SetError(p.run(ctx, workers, dataMap, opts))
 }
 
 type work struct {
 	out     chan<- output
-	wg      *sync.WaitGroup
 	builder *builder
-	dataMap map[string]any
+	dataMap Result        // snapshot of the data built so far
+	timeout time.Duration // maximum duration the builder is allowed to run
+	fan     *fanOutRun    // set for the calls of a fan-out builder
+	element int           // index of the element the fan-out builder is called with, -1 gathers the results
+	variant *builder      // variant chosen for the run when the builder was created with OneOf
 }
 
 type output struct {
-	outputs []reflect.Value
+	outputs []any
 	builder *builder
 	err     error
+	warning error // error of the builder when its fallback was used instead
+	fan     *fanOutRun
+	element int
+	variant string // name of the variant chosen for the run
+}
+
+// fanOutRun tracks the elements of a fan-out builder during a run
+type fanOutRun struct {
+	elements []any
+	results  []any
+	errs     []error
+	left     int       // number of elements not yet built
+	dataMap  Result    // snapshot of the data the fan-out builder was split with
+	start    time.Time // time the fan-out builder was split
+}
+
+// isElement checks if the work calls a fan-out builder with a single element
+func (w work) isElement() bool {
+	return w.fan != nil && w.element >= 0
+}
+
+// call returns the function the work calls
+func (w work) call() func(ctx context.Context, args []any) ([]any, error) {
+	if w.isElement() {
+		return w.builder.each.call
+	}
+	if w.variant != nil {
+		return w.variant.call
+	}
+	return w.builder.call
+}
+
+// split returns one work per element of the input of the fan-out builder, followed by the work
+// gathering their results once all of them are built. Without elements only the gathering work is returned
+func (w work) split(data any) []work {
+	elements := w.builder.each.split(data)
+	fan := &fanOutRun{
+		elements: elements,
+		results:  make([]any, len(elements)),
+		errs:     make([]error, len(elements)),
+		left:     len(elements),
+		dataMap:  w.dataMap,
+		start:    time.Now(),
+	}
+	works := make([]work, 0, len(elements))
+	for i := range elements {
+		e := w
+		e.fan, e.element = fan, i
+		works = append(works, e)
+	}
+	if len(works) == 0 {
+		w.fan, w.element = fan, -1
+		works = append(works, w)
+	}
+	return works
+}
+
+// add records the result of an element, it reports whether all elements are built
+func (f *fanOutRun) add(o output) bool {
+	if o.err != nil {
+		eErr := &ElementError{Index: o.element, Err: o.err}
+		var bErr *BuilderError
+		if errors.As(o.err, &bErr) {
+			eErr.Err, eErr.PanicValue, eErr.Stack = bErr.Err, bErr.PanicValue, bErr.Stack
+		}
+		f.errs[o.element] = eErr
+	} else {
+		f.results[o.element] = o.outputs[0]
+	}
+	f.left--
+	return f.left == 0
 }
 
 func worker(ctx context.Context, wChan <-chan work) {
@@ -115,89 +221,221 @@ func worker(ctx context.Context, wChan <-chan work) {
 }
 
 func processWork(ctx context.Context, w work) {
-	defer w.wg.Done() // ensure we close wait group
+	if w.fan != nil && !w.isElement() {
+		gather(ctx, w)
+		return
+	}
 	span, ctx := tracing.NewInternalSpan(ctx, w.builder.Name)
 	defer span.End()
-	o := output{builder: w.builder}
-	defer func() {
-		// recover from panic and set error
-		if r := recover(); r != nil {
-			o.err = span.SetError(errors.New("panic in builder: " + w.builder.Name))
-			w.out <- o
-		}
-	}()
-	fn := w.builder.fnValue
+	o := output{builder: w.builder, fan: w.fan, element: w.element}
 	// allow builders to access already built data
 	ctx = AddResultToCtx(ctx, w.dataMap)
-	args := make([]reflect.Value, 1)
-	args[0] = reflect.ValueOf(ctx) // first arg is context.Context
-	for _, in := range w.builder.In {
-		data, ok := w.dataMap[in]
-		if !ok {
-			o.err = ErrWTF
+	var args []any
+	if w.isElement() {
+		span.SetTag("element", w.element)
+		args = []any{w.fan.elements[w.element]}
+	} else {
+		params := w.builder.params
+		if len(w.builder.variants) > 0 {
+			v, err := w.builder.choose(ctx, w.dataMap)
+			if err != nil {
+				finish(ctx, span, w, o, newBuilderError(w.builder, err, nil, nil))
+				return
+			}
+			span.SetTag("variant", v.Name)
+			w.variant, o.variant = v, v.Name
+			params = v.params
+		}
+		var ok bool
+		if args, ok = buildArgs(params, w.dataMap); !ok {
+			o.err = span.SetError(newBuilderError(w.builder, ErrWTF, nil, nil))
 			w.out <- o
 			return
 		}
-		args = append(args, reflect.ValueOf(data))
 	}
-	o.outputs = fn.Call(args)
-	if len(o.outputs) > 1 && !o.outputs[1].IsNil() {
-		secondReturn := o.outputs[1].Interface()
-		if errVal, ok := secondReturn.(error); ok {
-			span.SetError(errVal) //nolint:errcheck
-		} else {
-			span.SetError(fmt.Errorf("builder %s: second return value is not an error (type %T)", w.builder.Name, secondReturn)) //nolint:errcheck
+	var bErr *BuilderError
+	attempts := 0
+	start := time.Now()
+	for {
+		attempts++
+		o.outputs, bErr = callBuilder(ctx, w, args)
+		if bErr == nil || bErr.PanicValue != nil || !w.builder.retry.shouldRetry(ctx, attempts, bErr.Err) {
+			break
+		}
+		if !w.builder.retry.wait(ctx, attempts) {
+			break
 		}
 	}
-	w.out <- o
+	if w.builder.retry != nil {
+		span.SetTag("attempts", attempts)
+	}
+	if !w.isElement() {
+		startShadow(ctx, w, o.outputs, bErr, time.Since(start))
+	}
+	finish(ctx, span, w, o, bErr)
 }
 
-func doWorkAndGetResult(ctx context.Context, builders []*builder, dataMap map[string]any, wChan chan<- work) error {
-	// create a output channel to read results
-	outChan := make(chan output, len(builders)+1)
-	// create a wait group to wait for all results
-	var wg sync.WaitGroup
-	for j := range builders {
-		b := builders[j]
-		if _, ok := dataMap[b.Out]; ok {
-			// do not run the builder if the data already exists
+// startShadow runs the candidate of the builder in the background when the builder has one
+func startShadow(ctx context.Context, w work, outputs []any, bErr *BuilderError, d time.Duration) {
+	sh := w.builder.shadow
+	if sh == nil {
+		return
+	}
+	report := ShadowReport{Builder: w.builder.Name, LiveDuration: d}
+	if bErr != nil {
+		// the error is updated when the fallback fails, the candidate gets its own copy
+		liveErr := *bErr
+		report.LiveErr = &liveErr
+	} else {
+		report.Live = outputs
+	}
+	go sh.run(ctx, w, report)
+}
+
+// buildArgs returns the arguments of a builder with the given params from the data,
+// it reports false if a required input is missing
+func buildArgs(params []param, dataMap Result) ([]any, bool) {
+	args := make([]any, 0, len(params))
+	for _, p := range params {
+		if p.inject != nil {
+			data, ok := p.inject.build(dataMap)
+			if !ok {
+				return nil, false
+			}
+			args = append(args, data)
 			continue
 		}
-		// build work
-		w := work{}
-		w.builder = b
-		w.wg = &wg
-		w.dataMap = dataMap
-		w.out = outChan
-		wg.Add(1)  // increment count
-		wChan <- w // send work to be done by workers
-	}
-	wg.Wait() // wait for work to be processed
-	close(outChan)
-	errs := make([]error, 0)
-	for o := range outChan {
-		if o.err != nil {
-			// error occurred, return it back and stop processing
-			return o.err
-		}
-		outputs := o.outputs
-		// we should only ever have two outputs
-		// 0-> data, 1-> error
-		if !outputs[1].IsNil() {
-			// error occurred, add it to the list of errors and continue processing
-			secondReturn := outputs[1].Interface()
-			if errVal, ok := secondReturn.(error); ok {
-				errs = append(errs, errVal)
-			} else {
-				errs = append(errs, fmt.Errorf("builder %s: second return value is not an error (type %T)", o.builder.Name, secondReturn))
-			}
+		data, ok := dataMap[p.name]
+		if p.optional != nil {
+			args = append(args, newOptional(p.optional, data, ok))
 			continue
 		}
-		// add result
-		name := getStructName(outputs[0].Type())
-		dataMap[name] = outputs[0].Interface()
+		if !ok {
+			return nil, false
+		}
+		args = append(args, data)
+	}
+	return args, true
+}
+
+// gather builds the output of a fan-out builder from the results of its elements
+func gather(ctx context.Context, w work) {
+	span, ctx := tracing.NewInternalSpan(ctx, w.builder.Name)
+	defer span.End()
+	span.SetTag("elements", len(w.fan.elements))
+	o := output{builder: w.builder}
+	var errs []error
+	for _, err := range w.fan.errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	var bErr *BuilderError
+	if len(errs) > 0 {
+		bErr = newBuilderError(w.builder, joinErrors(errs), nil, nil)
+	} else {
+		o.outputs = []any{w.builder.each.gather(w.fan.results)}
+	}
+	// the candidate is called with the whole input once the elements are built
+	w.dataMap = w.fan.dataMap
+	startShadow(ctx, w, o.outputs, bErr, time.Since(w.fan.start))
+	finish(ctx, span, w, o, bErr)
+}
+
+// finish sends the output of the work, the fallback of the builder is used when the builder failed.
+// Elements of fan-out builders do not fall back on their own, the fallback replaces the whole output
+func finish(ctx context.Context, span tracing.Span, w work, o output, bErr *BuilderError) {
+	if bErr != nil && w.builder.fallback != nil && !w.isElement() {
+		values, err := callFallback(ctx, w.builder, bErr)
+		if err == nil {
+			span.SetTag("fallback", true)
+			o.outputs = values
+			o.warning = bErr
+			w.out <- o
+			return
+		}
+		bErr.Err = fmt.Errorf("%w, fallback failed: %w", bErr.Err, err)
+	}
+	if bErr != nil {
+		o.err = span.SetError(bErr)
+	}
+	w.out <- o
+}
+
+// callBuilder calls the builder function once with the given args, applying the timeout of the work
+func callBuilder(ctx context.Context, w work, args []any) (outputs []any, bErr *BuilderError) {
+	defer func() {
+		// recover from panic and set error
+		if r := recover(); r != nil {
+			bErr = newBuilderError(w.builder, ErrBuilderPanic, r, debug.Stack())
+		}
+	}()
+	parent := ctx
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+	outputs, err := w.call()(ctx, args)
+	if err == nil {
+		if slices.ContainsFunc(outputs, isNilPointer) {
+			return outputs, newBuilderError(w.builder, ErrNilOutput, nil, nil)
+		}
+		return outputs, nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil && !errors.Is(err, context.DeadlineExceeded) {
+		// the builder timed out, make sure the error says so
+		err = fmt.Errorf("%w after %s: %w", context.DeadlineExceeded, w.timeout, err)
+	}
+	return outputs, newBuilderError(w.builder, err, nil, nil)
+}
+
+// callFallback calls the fallback of the builder for the error the builder failed with
+func callFallback(ctx context.Context, b *builder, bErr *BuilderError) (values []any, err error) {
+	defer func() {
+		// recover from panic and set error
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrBuilderPanic, r)
+		}
+	}()
+	values, err = b.fallback(ctx, bErr)
+	if err == nil && slices.ContainsFunc(values, isNilPointer) {
+		return values, ErrNilOutput
+	}
+	return values, err
+}
+
+// isNilPointer checks if v is a nil pointer
+func isNilPointer(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+func newBuilderError(b *builder, err error, panicValue any, stack []byte) *BuilderError {
+	return &BuilderError{
+		Builder:    b.Name,
+		Outputs:    b.Out,
+		Inputs:     b.In,
+		Err:        err,
+		PanicValue: panicValue,
+		Stack:      stack,
 	}
-	return joinErrors(errs)
+}
+
+// addOutput adds the output of a builder to the dataMap, or returns the error the builder failed with
+func addOutput(o output, dataMap map[string]any) error {
+	if o.err != nil {
+		return o.err
+	}
+	// add results
+	for i, out := range o.builder.Out {
+		if _, ok := dataMap[out]; ok {
+			// initial data is never overwritten
+			continue
+		}
+		dataMap[out] = o.outputs[i]
+	}
+	return nil
 }
 
 // joinErrors returns nil for no errors, the error itself for a single error,
@@ -214,11 +452,117 @@ func joinErrors(errs []error) error {
 	}
 }
 
-func (p *plan) run(ctx context.Context, workers uint, dataMap map[string]any) error {
+// schedule tracks the builders of a plan that are yet to be executed,
+// a builder becomes ready as soon as all of its inputs have been built
+type schedule struct {
+	ready   []*builder
+	pending map[*builder]int      // number of inputs a builder is still waiting for
+	waiting map[string][]*builder // mapping between data and the builders waiting for it
+	failed  map[string]string     // mapping between data that could not be built and the builder that caused it
+	left    int                   // number of builders not yet dispatched
+}
+
+func newSchedule(order [][]*builder, dataMap map[string]any) *schedule {
+	s := &schedule{
+		pending: make(map[*builder]int),
+		waiting: make(map[string][]*builder),
+		failed:  make(map[string]string),
+	}
+	produced := newStringSet()
+	builders := make([]*builder, 0)
+	for i := range order {
+		for _, b := range order[i] {
+			missing := make([]string, 0, len(b.Out))
+			for _, out := range b.Out {
+				if _, ok := dataMap[out]; !ok {
+					missing = append(missing, out)
+				}
+			}
+			if len(missing) == 0 {
+				// do not run the builder if the data already exists
+				continue
+			}
+			produced.Insert(missing...)
+			builders = append(builders, b)
+		}
+	}
+	for _, b := range builders {
+		for _, in := range append(b.In[:len(b.In):len(b.In)], b.Optional...) {
+			if produced.Has(in) {
+				s.pending[b]++
+				s.waiting[in] = append(s.waiting[in], b)
+			}
+		}
+		if s.pending[b] == 0 {
+			s.ready = append(s.ready, b)
+		}
+	}
+	s.left = len(builders)
+	return s
+}
+
+// next returns the next builder that is ready to be executed
+func (s *schedule) next() *builder {
+	b := s.ready[0]
+	s.ready = s.ready[1:]
+	s.left--
+	return b
+}
+
+// done marks the builder as executed and releases the builders waiting on its output
+func (s *schedule) done(b *builder) {
+	for _, out := range b.Out {
+		for _, w := range s.waiting[out] {
+			s.pending[w]--
+			if s.pending[w] == 0 {
+				s.ready = append(s.ready, w)
+			}
+		}
+	}
+}
+
+// fail marks the output of the builder as not built because of the failure of the cause builder
+func (s *schedule) fail(b *builder, cause string) {
+	for _, out := range b.Out {
+		s.failed[out] = cause
+	}
+}
+
+// skipFailed removes the ready builders that depend on data that could not be built,
+// their dependents are skipped in turn as they become ready
+func (s *schedule) skipFailed() []error {
+	errs := make([]error, 0)
+	for i := 0; i < len(s.ready); {
+		b := s.ready[i]
+		input, cause := "", ""
+		for _, in := range b.In {
+			if c, ok := s.failed[in]; ok {
+				input, cause = in, c
+				break
+			}
+		}
+		if cause == "" {
+			i++
+			continue
+		}
+		s.ready = append(s.ready[:i], s.ready[i+1:]...)
+		s.left--
+		errs = append(errs, &DependencyFailedError{Builder: b.Name, Input: input, FailedBuilder: cause})
+		s.fail(b, cause)
+		s.done(b)
+	}
+	return errs
+}
+
+func (p *plan) run(ctx context.Context, workers uint, dataMap map[string]any, opts runOptions) error {
 	if workers == 0 {
 		workers = 1
 	}
 
+	// cancelling the context stops all in flight builders when failing fast
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	// create a work channel and start workers
 	wChan := make(chan work)
 	defer close(wChan)
@@ -226,30 +570,126 @@ func (p *plan) run(ctx context.Context, workers uint, dataMap map[string]any) er
 		go worker(ctx, wChan)
 	}
 
+	s := newSchedule(p.order, dataMap)
+	// create a output channel to read results, it is buffered for one output per builder. Fan-out builders
+	// have one output per element, workers wait on it until they are collected when there are more
+	outChan := make(chan output, s.left)
 	errs := make([]error, 0)
-	for i := range p.order {
-		if err := ctx.Err(); err != nil {
-			if len(errs) == 0 {
-				return err
+	warnings := make([]error, 0)
+	variants := make(map[string]string)
+	inFlight := 0
+	queue := make([]work, 0) // works of fan-out builders waiting for a worker
+	// ready builders and elements of fan-out builders take turns, so neither of them holds back the other
+	elementsTurn := false
+	done := ctx.Done()
+	var snapshot Result
+	for {
+		errs = append(errs, s.skipFailed()...)
+		if len(s.ready) > 0 && s.ready[0].each != nil && ctx.Err() == nil {
+			// fan-out builders are dispatched one element at a time
+			if snapshot == nil {
+				snapshot = maps.Clone(Result(dataMap))
 			}
-			return joinErrors(append(errs, err))
+			b := s.next()
+			w := work{out: outChan, builder: b, dataMap: snapshot, timeout: p.timeout(b)}
+			queue = append(queue, w.split(dataMap[b.In[0]])...)
+			continue
 		}
-		err := doWorkAndGetResult(ctx, p.order[i], dataMap, wChan)
-		if err != nil {
-			errs = append(errs, err)
+		// dispatch ready builders while workers are available and collect
+		// results as they come in, a builder is never held back by unrelated builders
+		var sendChan chan<- work
+		var w work
+		if len(queue) > 0 && (elementsTurn || len(s.ready) == 0) && ctx.Err() == nil {
+			sendChan = wChan
+			w = queue[0]
+		} else if len(s.ready) > 0 && ctx.Err() == nil {
+			if snapshot == nil {
+				snapshot = maps.Clone(Result(dataMap))
+			}
+			sendChan = wChan
+			w = work{out: outChan, builder: s.ready[0], dataMap: snapshot, timeout: p.timeout(s.ready[0])}
+		} else if inFlight == 0 {
+			break
+		}
+		select {
+		case sendChan <- w:
+			if w.fan != nil {
+				queue = queue[1:]
+			} else {
+				s.next()
+			}
+			elementsTurn = w.fan == nil
+			inFlight++
+		case o := <-outChan:
+			inFlight--
+			if o.fan != nil {
+				if o.fan.add(o) {
+					// all elements are built, gather their results
+					queue = append(queue, work{out: outChan, builder: o.builder, timeout: p.timeout(o.builder), fan: o.fan, element: -1})
+				}
+				if o.err != nil && opts.failFast && o.builder.fallback == nil {
+					// the builder fails with the first element that fails, the other elements are cancelled.
+					// Builders with a fallback fall back once all elements are built instead
+					errs = append(errs, newBuilderError(o.builder, o.fan.errs[o.element], nil, nil))
+					cancel()
+				}
+				continue
+			}
+			if err := addOutput(o, dataMap); err != nil {
+				errs = append(errs, err)
+				s.fail(o.builder, o.builder.Name)
+				if opts.failFast {
+					cancel()
+				}
+			} else {
+				snapshot = nil
+			}
+			if o.warning != nil {
+				warnings = append(warnings, o.warning)
+			}
+			if o.variant != "" {
+				variants[o.builder.Name] = o.variant
+			}
+			s.done(o.builder)
+		case <-done:
+			// stop dispatching, in flight builders are still collected
+			done = nil
 		}
 	}
+	if len(warnings) > 0 {
+		dataMap[getStructName(reflect.TypeFor[Warnings]())] = Warnings{Errors: warnings}
+	}
+	if len(variants) > 0 {
+		dataMap[getStructName(reflect.TypeFor[Variants]())] = Variants{Chosen: variants}
+	}
+	if opts.failFast && len(errs) > 0 {
+		// errors of the builders that were cancelled are not interesting
+		return errs[0]
+	}
+	if s.left > 0 || len(queue) > 0 {
+		err := ctx.Err()
+		if err == nil {
+			// builders left that never became ready
+			err = ErrWTF
+		}
+		if len(errs) == 0 {
+			return err
+		}
+		return joinErrors(append(errs, err))
+	}
 	return joinErrors(errs)
 }
 
 // Result.Get returns the value of the struct from the result
 // if the struct is not found in the result, nil is returned
+//
+// pointers are looked up by their type, a nil pointer of type *T can be used to get *T from the result
 func (r Result) Get(obj any) any {
 	if obj == nil || r == nil {
 		return nil
 	}
 	t := reflect.TypeOf(obj)
-	if t.Kind() != reflect.Struct {
+	if !isDataType(t) {
 		return nil
 	}
 	name := getStructName(t)
@@ -259,6 +699,33 @@ func (r Result) Get(obj any) any {
 	return nil
 }
 
+// Get returns the value of type T from the result, the second return value
+// reports whether the value was found
+//
+// this is the type-safe alternative to Result.Get, no zero value of T needs to be
+// allocated and no type assertion is needed at the call site
+func Get[T any](r Result) (T, bool) {
+	var zero T
+	if r == nil {
+		return zero, false
+	}
+	value, ok := r[getStructName(reflect.TypeFor[T]())]
+	if !ok {
+		return zero, false
+	}
+	v, ok := value.(T)
+	return v, ok
+}
+
+// MustGet returns the value of type T from the result and panics if it is not found
+func MustGet[T any](r Result) T {
+	v, ok := Get[T](r)
+	if !ok {
+		panic("databuilder: " + getStructName(reflect.TypeFor[T]()) + " not found in result")
+	}
+	return v
+}
+
 // BuildGraph builds a graphviz graph of the dependency graph of the plan and writes it to the file specified.
 func (p plan) BuildGraph(ctx context.Context, format, file string) error {
 	const (
@@ -282,14 +749,16 @@ func (p plan) BuildGraph(ctx context.Context, format, file string) error {
 				return err
 			}
 			fn = fn.SetFontColor(FNCOLOR)
-			out, err := graph.CreateNodeByName(b.Out)
-			if err != nil {
-				return err
-			}
-			out = out.SetFontColor(STRUCTCOLOR)
-			_, err = graph.CreateEdgeByName("Out", fn, out)
-			if err != nil {
-				return err
+			for _, out := range b.Out {
+				out, err := graph.CreateNodeByName(out)
+				if err != nil {
+					return err
+				}
+				out = out.SetFontColor(STRUCTCOLOR)
+				_, err = graph.CreateEdgeByName("Out", fn, out)
+				if err != nil {
+					return err
+				}
 			}
 			for _, in := range b.In {
 				in, err := graph.CreateNodeByName(in)
@@ -302,15 +771,36 @@ func (p plan) BuildGraph(ctx context.Context, format, file string) error {
 					return err
 				}
 			}
+			for _, in := range b.Optional {
+				in, err := graph.CreateNodeByName(in)
+				if err != nil {
+					return err
+				}
+				in = in.SetFontColor(STRUCTCOLOR)
+				_, err = graph.CreateEdgeByName("Optional", in, fn)
+				if err != nil {
+					return err
+				}
+			}
 		}
 	}
 	return g.RenderFilename(ctx, graph, graphviz.Format(format), file)
 }
 
-func newPlan(order [][]*builder, initData []string) (Plan, error) {
+// timeout returns the maximum duration the builder is allowed to run in this plan
+func (p *plan) timeout(b *builder) time.Duration {
+	if b.timeout > 0 {
+		return b.timeout
+	}
+	return p.opts.defaultTimeout
+}
+
+func newPlan(order [][]*builder, initData []string, bindings map[string]string, opts options) (Plan, error) {
 	return &plan{
 		order:    order,
 		initData: newStringSet(initData...),
+		bindings: bindings,
+		opts:     opts,
 	}, nil
 }
 
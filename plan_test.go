@@ -2,9 +2,12 @@ package databuilder
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/goleak"
@@ -70,6 +73,14 @@ func TestPlanRunPartialSuccess(t *testing.T) {
 		},
 	)
 	assert.Error(t, err, "DBTestFunc encounterd an error")
+	assert.ErrorIs(t, err, ErrDependencyFailed, "downstream of error should be skipped")
+	assert.NotErrorIs(t, err, ErrWTF, "failure of a builder is not a resolution bug")
+	var depErr *DependencyFailedError
+	if assert.ErrorAs(t, err, &depErr) {
+		assert.Contains(t, depErr.Builder, "DBTestFuncAfterErr")
+		assert.Contains(t, depErr.FailedBuilder, "DBTestFuncErr")
+		assert.Equal(t, getStructName(reflect.TypeFor[TestStruct2]()), depErr.Input)
+	}
 
 	var t2 TestStruct2
 	data := result.Get(t2)
@@ -135,3 +146,244 @@ func ExamplePlan() {
 	// CALLED DBTestFunc4
 	// true
 }
+
+func TestResultGetGeneric(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFunc, DBTestFunc4)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+
+	ts2, ok := Get[TestStruct2](result)
+	assert.True(t, ok)
+	assert.Equal(t, "a_b", ts2.Value)
+	assert.Equal(t, "a-b", MustGet[TestStruct3](result).Value)
+
+	_, ok = Get[TestStruct5](result)
+	assert.False(t, ok, "missing data should not be found")
+	_, ok = Get[TestStruct1](nil)
+	assert.False(t, ok, "nil result should not panic")
+	assert.Panics(t, func() { MustGet[TestStruct5](result) })
+}
+
+func TestFromContext(t *testing.T) {
+	ctx := AddResultToCtx(context.Background(), Result{
+		getStructName(reflect.TypeFor[TestStruct1]()): TestStruct1{Value: "ctx"},
+	})
+	ts1, ok := FromContext[TestStruct1](ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "ctx", ts1.Value)
+
+	_, ok = FromContext[TestStruct2](ctx)
+	assert.False(t, ok)
+	_, ok = FromContext[TestStruct1](context.Background())
+	assert.False(t, ok, "context without result should not be found")
+}
+
+func TestPlanRunParallelDoesNotWaitForUnrelatedBuilders(t *testing.T) {
+	// slow is in the first level of the plan and only finishes once a builder
+	// from the second level has run, this can only succeed if builders are
+	// dispatched as soon as their own inputs are available
+	built := make(chan struct{})
+	slow := func(_ context.Context, s TestStruct1) (TestStruct2, error) {
+		select {
+		case <-built:
+			return TestStruct2(s), nil
+		case <-time.After(5 * time.Second):
+			return TestStruct2{}, errors.New("builder was blocked by an unrelated builder")
+		}
+	}
+	fast := func(_ context.Context, s TestStruct1) (TestStruct3, error) {
+		return TestStruct3(s), nil
+	}
+	dependent := func(_ context.Context, s TestStruct3) (TestStruct4, error) {
+		close(built)
+		return TestStruct4(s), nil
+	}
+
+	d := testNew(t)
+	err := d.AddBuilders(slow, fast, dependent)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestStruct1{Value: "dag"})
+	assert.NoError(t, err)
+	assert.Equal(t, "dag", MustGet[TestStruct2](result).Value)
+	assert.Equal(t, "dag", MustGet[TestStruct4](result).Value)
+	goleak.VerifyNone(t)
+}
+
+func TestPlanRunSkipsTransitiveDependents(t *testing.T) {
+	afterAfterErr := func(_ context.Context, s TestStruct5) (TestStruct3, error) {
+		return TestStruct3(s), nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFuncErr, DBTestFuncAfterErr, afterAfterErr)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = executionPlan.Run(context.Background(), TestStruct1{Value: "test"})
+	assert.Error(t, err)
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !assert.True(t, ok, "all failures should be reported") {
+		return
+	}
+	skipped := make(map[string]*DependencyFailedError)
+	for _, e := range joined.Unwrap() {
+		var depErr *DependencyFailedError
+		if errors.As(e, &depErr) {
+			skipped[depErr.Input] = depErr
+		}
+	}
+	assert.Len(t, skipped, 2)
+	for _, depErr := range skipped {
+		assert.Contains(t, depErr.FailedBuilder, "DBTestFuncErr", "root cause should be reported")
+	}
+	assert.Contains(t, skipped[getStructName(reflect.TypeFor[TestStruct5]())].Builder, "func")
+	goleak.VerifyNone(t)
+}
+
+func TestPlanRunBuilderError(t *testing.T) {
+	panics := func(_ context.Context, _ TestStruct1) (TestStruct2, error) {
+		panic("boom")
+	}
+	cancelled := func(_ context.Context, _ TestStruct1) (TestStruct3, error) {
+		return TestStruct3{}, context.Canceled
+	}
+
+	d := testNew(t)
+	err := d.AddBuilders(panics)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, ErrBuilderPanic)
+	var bErr *BuilderError
+	if assert.ErrorAs(t, err, &bErr) {
+		assert.Contains(t, bErr.Builder, "TestPlanRunBuilderError")
+		assert.Equal(t, []string{getStructName(reflect.TypeFor[TestStruct2]())}, bErr.Outputs)
+		assert.Equal(t, []string{getStructName(reflect.TypeFor[TestStruct1]())}, bErr.Inputs)
+		assert.Equal(t, "boom", bErr.PanicValue)
+		assert.NotEmpty(t, bErr.Stack)
+		assert.ErrorContains(t, err, "boom")
+	}
+
+	d = testNew(t)
+	err = d.AddBuilders(cancelled)
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, context.Canceled, "sentinel checks should work through BuilderError")
+	if assert.ErrorAs(t, err, &bErr) {
+		assert.Nil(t, bErr.PanicValue)
+		assert.Nil(t, bErr.Stack)
+	}
+	goleak.VerifyNone(t)
+}
+
+func TestPlanRunPointers(t *testing.T) {
+	toPointer := func(_ context.Context, s TestStruct3) (*TestStruct2, error) {
+		return &TestStruct2{Value: s.Value}, nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(toPointer, DBTestFuncPointer)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct3{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct3{Value: "ptr"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ptr", MustGet[TestStruct1](result).Value)
+	ts2, ok := Get[*TestStruct2](result)
+	assert.True(t, ok)
+	assert.Equal(t, "ptr", ts2.Value)
+	assert.Equal(t, ts2, result.Get((*TestStruct2)(nil)), "pointers should be looked up by type")
+	assert.Nil(t, result.Get(TestStruct2{}), "*T and T should be different data")
+
+	// pointers as initial data
+	executionPlan, err = d.Compile(&TestStruct2{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), &TestStruct2{Value: "init"})
+	assert.NoError(t, err)
+	assert.Equal(t, "init", MustGet[TestStruct1](result).Value)
+	_, err = executionPlan.Run(context.Background(), (*TestStruct2)(nil))
+	assert.ErrorIs(t, err, ErrInitialDataMissing, "nil pointers should be ignored")
+	goleak.VerifyNone(t)
+}
+
+func TestPlanRunNilPointerOutput(t *testing.T) {
+	nilOutput := func(_ context.Context, _ TestStruct3) (*TestStruct2, error) {
+		return nil, nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(nilOutput, DBTestFuncPointer)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct3{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct3{})
+	assert.ErrorIs(t, err, ErrNilOutput)
+	assert.ErrorIs(t, err, ErrDependencyFailed)
+	_, ok := Get[*TestStruct2](result)
+	assert.False(t, ok)
+	goleak.VerifyNone(t)
+}
+
+func TestPlanRunMultipleOutputs(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(DBTestFuncMulti, DBTestFunc7, DBTestFuncAfterErr)
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestStruct1{Value: "m"})
+	assert.NoError(t, err)
+	assert.Equal(t, "m2", MustGet[TestStruct2](result).Value)
+	assert.Equal(t, "m3", MustGet[TestStruct3](result).Value)
+	assert.Equal(t, "m3", MustGet[TestStruct4](result).Value)
+	assert.Equal(t, "m2", MustGet[TestStruct5](result).Value)
+
+	// outputs provided as initial data are not overwritten
+	executionPlan, err = d.Compile(TestStruct1{}, TestStruct2{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "m"}, TestStruct2{Value: "init"})
+	assert.NoError(t, err)
+	assert.Equal(t, "init", MustGet[TestStruct2](result).Value)
+	assert.Equal(t, "m3", MustGet[TestStruct3](result).Value)
+
+	// failure skips the dependents of all outputs
+	failing := func(_ context.Context, _ TestStruct1) (TestStruct2, TestStruct3, error) {
+		return TestStruct2{}, TestStruct3{}, errors.New("failed")
+	}
+	d = testNew(t)
+	err = d.AddBuilders(failing, DBTestFunc7, DBTestFuncAfterErr)
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	joined, ok := err.(interface{ Unwrap() []error })
+	if assert.True(t, ok) {
+		assert.Len(t, joined.Unwrap(), 3)
+	}
+
+	// defaults for all outputs
+	d = testNew(t)
+	err = d.AddBuilders(Configure(failing, WithDefault(TestStruct2{Value: "d2"}, TestStruct3{Value: "d3"})), DBTestFunc7)
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.NoError(t, err)
+	assert.Equal(t, "d2", MustGet[TestStruct2](result).Value)
+	assert.Equal(t, "d3", MustGet[TestStruct4](result).Value)
+	assert.ErrorIs(t, d.AddBuilders(Configure(DBTestFuncMulti, WithDefault(TestStruct2{}))), ErrInvalidOption)
+	goleak.VerifyNone(t)
+}
@@ -0,0 +1,121 @@
+package databuilder
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// qualifiedName returns the name of data of the given name that is qualified with qualifier
+func qualifiedName(name, qualifier string) string {
+	return name + "@" + qualifier
+}
+
+// Named registers the builder under a qualifier, all outputs of the builder are qualified with it
+// so the same type can be produced by more than one builder, e.g. a builder registered with
+//
+//	Named("billing", AddressBuilder)
+//
+// produces Address@billing, which is different data from Address@shipping and Address.
+// Builders consume qualified data with WithQualifiedInput and it is read from the Result with GetNamed.
+// The same function can be registered under multiple qualifiers, to replace it in a Plan pass the
+// function wrapped with Named to Plan.Replace
+func Named(qualifier string, fn any, opts ...BuilderOption) any {
+	return Configure(fn, append([]BuilderOption{withQualifier(qualifier)}, opts...)...)
+}
+
+func withQualifier(qualifier string) BuilderOption {
+	return func(b *builder) error {
+		if qualifier == "" {
+			return fmt.Errorf("%w: qualifier should not be empty", ErrInvalidOption)
+		}
+		for i := range b.Out {
+			b.Out[i] = qualifiedName(b.Out[i], qualifier)
+		}
+		// the same function can be registered under different qualifiers
+		b.Name = qualifiedName(b.Name, qualifier)
+		return nil
+	}
+}
+
+// WithQualifiedInput makes the builder consume the input of the type of obj qualified with qualifier,
+// the input is produced by a builder registered with Named or provided as initial data with NamedValue.
+// When the builder has more than one parameter of that type a qualifier is given for each of them in
+// the order of the parameters, an empty qualifier leaves the parameter unqualified
+//
+//	func Label(ctx context.Context, billing, shipping Address) (Label, error)
+//	Configure(Label, WithQualifiedInput(Address{}, "billing", "shipping"))
+//
+// builders created with OneOf are fed by the inputs of their variants, the inputs are qualified on the variants instead.
+// The same goes for builders created with Shadow, the inputs are qualified on both the live builder and the candidate
+func WithQualifiedInput(obj any, qualifiers ...string) BuilderOption {
+	return func(b *builder) error {
+		if obj == nil || len(qualifiers) == 0 {
+			return fmt.Errorf("%w: qualified input needs a type and a qualifier", ErrInvalidOption)
+		}
+		if len(b.variants) > 0 {
+			return fmt.Errorf("%w: qualify the inputs of the variants of %s instead", ErrInvalidOption, b.Name)
+		}
+		if b.shadow != nil {
+			return fmt.Errorf("%w: qualify the inputs of %s and of its candidate instead", ErrInvalidOption, b.Name)
+		}
+		name := getStructName(reflect.TypeOf(obj))
+		matched := 0
+		for _, p := range b.inputs() {
+			if p.name != name {
+				continue
+			}
+			if matched < len(qualifiers) && qualifiers[matched] != "" {
+				p.name = qualifiedName(name, qualifiers[matched])
+			}
+			matched++
+		}
+		if matched != len(qualifiers) {
+			return fmt.Errorf("%w: builder has %d inputs %s, got %d qualifiers", ErrInvalidOption, matched, name, len(qualifiers))
+		}
+		b.setInputs()
+		return nil
+	}
+}
+
+// namedValue is data provided to Compile or Run under a qualifier
+type namedValue struct {
+	qualifier string
+	value     any
+}
+
+// NamedValue qualifies initial data, the returned value can be passed to DataBuilder.Compile and Plan.Run
+// in place of the data to provide it to builders that consume it with WithQualifiedInput
+func NamedValue(qualifier string, value any) any {
+	return &namedValue{qualifier: qualifier, value: value}
+}
+
+// GetNamed returns the value of the type of obj qualified with qualifier from the result,
+// if the value is not found in the result, nil is returned
+func (r Result) GetNamed(obj any, qualifier string) any {
+	if obj == nil || r == nil {
+		return nil
+	}
+	t := reflect.TypeOf(obj)
+	if !isDataType(t) {
+		return nil
+	}
+	if value, ok := r[qualifiedName(getStructName(t), qualifier)]; ok {
+		return value
+	}
+	return nil
+}
+
+// GetNamed returns the value of type T qualified with qualifier from the result, the second return
+// value reports whether the value was found
+func GetNamed[T any](r Result, qualifier string) (T, bool) {
+	var zero T
+	if r == nil {
+		return zero, false
+	}
+	value, ok := r[qualifiedName(getStructName(reflect.TypeFor[T]()), qualifier)]
+	if !ok {
+		return zero, false
+	}
+	v, ok := value.(T)
+	return v, ok
+}
@@ -0,0 +1,94 @@
+package databuilder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func DBTestFuncQualified(_ context.Context, billing, shipping TestStruct2) (TestStruct3, error) {
+	return TestStruct3{
+		Value: billing.Value + "|" + shipping.Value,
+	}, nil
+}
+
+func TestNamed(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(
+		Named("billing", DBTestFunc),
+		Named("shipping", DBTestFunc5),
+	)
+	assert.NoError(t, err, "qualified outputs should not collide")
+	err = d.AddBuilders(DBTestFunc)
+	assert.NoError(t, err, "qualified outputs should not collide with unqualified ones")
+	err = d.AddBuilders(Named("billing", DBTestFunc5))
+	assert.ErrorIs(t, err, ErrMultipleBuilderSameOutput)
+	err = d.AddBuilders(Named("", DBTestFunc5))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+
+	err = d.AddBuilders(Configure(DBTestFuncQualified, WithQualifiedInput(TestStruct2{}, "billing", "shipping")))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a_b", MustGet[TestStruct2](result).Value)
+	billing, ok := GetNamed[TestStruct2](result, "billing")
+	assert.True(t, ok)
+	assert.Equal(t, "a_b", billing.Value)
+	assert.Equal(t, TestStruct2{Value: "a--b"}, result.GetNamed(TestStruct2{}, "shipping"))
+	assert.Equal(t, "a_b|a--b", MustGet[TestStruct3](result).Value, "qualified inputs should be consumed")
+	_, ok = GetNamed[TestStruct2](result, "other")
+	assert.False(t, ok)
+	goleak.VerifyNone(t)
+}
+
+func TestNamedSameFunction(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(Named("a", DBTestFunc), Named("b", DBTestFunc))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	_, ok := GetNamed[TestStruct2](result, "a")
+	assert.True(t, ok)
+	_, ok = GetNamed[TestStruct2](result, "b")
+	assert.True(t, ok)
+
+	err = executionPlan.Replace(context.Background(), Named("b", DBTestFunc), DBTestFunc5)
+	assert.NoError(t, err, "replacement should keep the qualifier")
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	b, _ := GetNamed[TestStruct2](result, "b")
+	assert.Equal(t, "a--b", b.Value)
+	goleak.VerifyNone(t)
+}
+
+func TestQualifiedInputs(t *testing.T) {
+	d := testNew(t)
+	err := d.AddBuilders(Configure(DBTestFunc, WithQualifiedInput(TestStruct5{}, "x")))
+	assert.ErrorIs(t, err, ErrInvalidOption, "builder should have the qualified input")
+	err = d.AddBuilders(Configure(DBTestFuncQualified, WithQualifiedInput(TestStruct2{}, "x")))
+	assert.ErrorIs(t, err, ErrInvalidOption, "every input of the type should be qualified")
+
+	err = d.AddBuilders(Configure(DBTestFunc4, WithQualifiedInput(TestStruct1{}, "init")))
+	assert.NoError(t, err)
+	_, err = d.Compile(TestStruct1{})
+	assert.ErrorIs(t, err, ErrCouldNotResolveDependency)
+	executionPlan, err := d.Compile(NamedValue("init", TestStruct1{}))
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), NamedValue("init", TestStruct1{Value: "named"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "named", MustGet[TestStruct3](result).Value)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{Value: "named"})
+	assert.ErrorIs(t, err, ErrInitialDataMissing)
+	_, err = executionPlan.Run(context.Background(), NamedValue("", TestStruct1{}))
+	assert.ErrorIs(t, err, ErrInvalidBuilderInput)
+	goleak.VerifyNone(t)
+}
@@ -0,0 +1,94 @@
+package databuilder
+
+import (
+	"context"
+	"reflect"
+)
+
+// typedBuilder is a builder function registered with one of the AddN functions,
+// it is called without reflection
+type typedBuilder struct {
+	fn   any
+	call func(ctx context.Context, args []any) ([]any, error)
+	ins  []reflect.Type
+	outs []reflect.Type
+	each *fanOut // set for fan-out builders created with Each
+}
+
+func addTyped[O any](d DataBuilder, fn any, call func(ctx context.Context, args []any) (O, error), ins []reflect.Type, opts []BuilderOption) error {
+	if d == nil {
+		return ErrInvalidBuilder
+	}
+	tb := &typedBuilder{
+		fn: fn,
+		call: func(ctx context.Context, args []any) ([]any, error) {
+			out, err := call(ctx, args)
+			return []any{out}, err
+		},
+		ins:  ins,
+		outs: []reflect.Type{reflect.TypeFor[O]()},
+	}
+	return d.AddBuilders(Configure(tb, opts...))
+}
+
+// Add0 adds a builder without inputs to the DataBuilder, the signature of the builder is checked by the compiler
+// and the builder is called without reflection. The builder is validated, named and configured the same way as
+// builders added with DataBuilder.AddBuilders
+func Add0[O any](d DataBuilder, fn func(context.Context) (O, error), opts ...BuilderOption) error {
+	if fn == nil {
+		return ErrInvalidBuilder
+	}
+	return addTyped(d, fn, func(ctx context.Context, _ []any) (O, error) {
+		return fn(ctx)
+	}, nil, opts)
+}
+
+// Add1 adds a builder with one input to the DataBuilder, see Add0
+func Add1[I1, O any](d DataBuilder, fn func(context.Context, I1) (O, error), opts ...BuilderOption) error {
+	if fn == nil {
+		return ErrInvalidBuilder
+	}
+	return addTyped(d, fn, func(ctx context.Context, args []any) (O, error) {
+		return fn(ctx, args[0].(I1))
+	}, []reflect.Type{reflect.TypeFor[I1]()}, opts)
+}
+
+// Add2 adds a builder with two inputs to the DataBuilder, see Add0
+func Add2[I1, I2, O any](d DataBuilder, fn func(context.Context, I1, I2) (O, error), opts ...BuilderOption) error {
+	if fn == nil {
+		return ErrInvalidBuilder
+	}
+	return addTyped(d, fn, func(ctx context.Context, args []any) (O, error) {
+		return fn(ctx, args[0].(I1), args[1].(I2))
+	}, []reflect.Type{reflect.TypeFor[I1](), reflect.TypeFor[I2]()}, opts)
+}
+
+// Add3 adds a builder with three inputs to the DataBuilder, see Add0
+func Add3[I1, I2, I3, O any](d DataBuilder, fn func(context.Context, I1, I2, I3) (O, error), opts ...BuilderOption) error {
+	if fn == nil {
+		return ErrInvalidBuilder
+	}
+	return addTyped(d, fn, func(ctx context.Context, args []any) (O, error) {
+		return fn(ctx, args[0].(I1), args[1].(I2), args[2].(I3))
+	}, []reflect.Type{reflect.TypeFor[I1](), reflect.TypeFor[I2](), reflect.TypeFor[I3]()}, opts)
+}
+
+// Add4 adds a builder with four inputs to the DataBuilder, see Add0
+func Add4[I1, I2, I3, I4, O any](d DataBuilder, fn func(context.Context, I1, I2, I3, I4) (O, error), opts ...BuilderOption) error {
+	if fn == nil {
+		return ErrInvalidBuilder
+	}
+	return addTyped(d, fn, func(ctx context.Context, args []any) (O, error) {
+		return fn(ctx, args[0].(I1), args[1].(I2), args[2].(I3), args[3].(I4))
+	}, []reflect.Type{reflect.TypeFor[I1](), reflect.TypeFor[I2](), reflect.TypeFor[I3](), reflect.TypeFor[I4]()}, opts)
+}
+
+// Add5 adds a builder with five inputs to the DataBuilder, see Add0
+func Add5[I1, I2, I3, I4, I5, O any](d DataBuilder, fn func(context.Context, I1, I2, I3, I4, I5) (O, error), opts ...BuilderOption) error {
+	if fn == nil {
+		return ErrInvalidBuilder
+	}
+	return addTyped(d, fn, func(ctx context.Context, args []any) (O, error) {
+		return fn(ctx, args[0].(I1), args[1].(I2), args[2].(I3), args[3].(I4), args[4].(I5))
+	}, []reflect.Type{reflect.TypeFor[I1](), reflect.TypeFor[I2](), reflect.TypeFor[I3](), reflect.TypeFor[I4](), reflect.TypeFor[I5]()}, opts)
+}
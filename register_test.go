@@ -0,0 +1,77 @@
+package databuilder
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestAddTyped(t *testing.T) {
+	d := testNew(t)
+	err := Add0(d, func(context.Context) (TestStruct1, error) {
+		return TestStruct1{Value: "a-b"}, nil
+	})
+	assert.NoError(t, err)
+	err = Add1(d, DBTestFunc)
+	assert.NoError(t, err)
+	err = Add2(d, func(_ context.Context, s1 TestStruct1, s2 Optional[TestStruct2]) (TestStruct3, error) {
+		return TestStruct3{Value: s1.Value + "|" + s2.Value.Value}, nil
+	}, WithName("join"))
+	assert.NoError(t, err)
+	err = Add1(d, DBTestFunc5)
+	assert.ErrorIs(t, err, ErrMultipleBuilderSameOutput)
+	err = Add1[TestStruct1, TestStruct2](d, nil)
+	assert.ErrorIs(t, err, ErrInvalidBuilder)
+
+	executionPlan, err := d.Compile()
+	assert.NoError(t, err)
+	result, err := executionPlan.RunParallel(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "a-b", MustGet[TestStruct1](result).Value)
+	assert.Equal(t, "a-b|a_b", MustGet[TestStruct3](result).Value)
+
+	err = executionPlan.Replace(context.Background(), DBTestFunc, DBTestFunc5)
+	assert.NoError(t, err, "typed builders can be replaced")
+	result, err = executionPlan.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "a--b", MustGet[TestStruct2](result).Value)
+}
+
+func TestAddTypedValidation(t *testing.T) {
+	d := testNew(t)
+	err := Add1(d, func(context.Context, TestStruct1) (int, error) {
+		return 0, nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidBuilderFirstOutput)
+	err = Add1(d, func(context.Context, int) (TestStruct1, error) {
+		return TestStruct1{}, nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidBuilderInput)
+	err = Add1(d, DBTestFuncInvalid5)
+	assert.ErrorIs(t, err, ErrSameInputAsOutput)
+	err = Add0[TestStruct1](nil, DBTestFunc2)
+	assert.ErrorIs(t, err, ErrInvalidBuilder)
+}
+
+func TestAddTypedOptions(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	d := testNew(t)
+	err := Add1(d, DBTestFuncErr, WithDefault(TestStruct2{Value: "default"}))
+	assert.NoError(t, err)
+	err = Add1(d, func(context.Context, TestStruct2) (TestStruct3, error) {
+		panic("typed panic")
+	})
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.Run(context.Background(), TestStruct1{})
+	assert.Equal(t, "default", MustGet[TestStruct2](result).Value)
+	var bErr *BuilderError
+	assert.True(t, errors.As(err, &bErr))
+	assert.ErrorIs(t, err, ErrBuilderPanic, "panics of typed builders should be recovered")
+	assert.Equal(t, "typed panic", bErr.PanicValue)
+}
@@ -0,0 +1,190 @@
+package databuilder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-coldbrew/tracing"
+	"github.com/google/go-cmp/cmp"
+)
+
+// defaultShadowTimeout is the timeout of candidates that are not given one with WithShadowTimeout
+const defaultShadowTimeout = time.Second
+
+// ShadowReport compares the outputs of a live builder and of the candidate run in its shadow
+type ShadowReport struct {
+	// Builder is the name of the live builder
+	Builder string
+	// Candidate is the name of the candidate builder
+	Candidate string
+	// Live are the outputs of the live builder, in the order they are returned. Nil if the builder failed
+	Live []any
+	// CandidateOutputs are the outputs of the candidate, in the order they are returned. Nil if the candidate failed
+	CandidateOutputs []any
+	// LiveErr is the error the live builder failed with, its fallback is not used for the comparison
+	LiveErr error
+	// CandidateErr is the error the candidate failed with, context.DeadlineExceeded when it timed out
+	CandidateErr error
+	// LiveDuration and CandidateDuration are the time the builders took, retries of the live builder included
+	LiveDuration, CandidateDuration time.Duration
+	// Diff describes the differences between the outputs, empty when the outputs are the same or both builders failed
+	Diff string
+}
+
+// ShadowOption configures how the candidate of Shadow is run and compared
+type ShadowOption func(*shadow)
+
+// WithShadowTimeout sets the maximum duration the candidate is allowed to run, one second by default
+func WithShadowTimeout(d time.Duration) ShadowOption {
+	return func(s *shadow) {
+		s.timeout = d
+	}
+}
+
+// WithComparer sets the function comparing every output of the live builder with the output of the candidate,
+// it returns an empty string when they are the same. Outputs are compared with cmp.Diff by default
+func WithComparer(compare func(live, candidate any) string) ShadowOption {
+	return func(s *shadow) {
+		s.compare = compare
+	}
+}
+
+// shadow is the candidate run in the shadow of a builder
+type shadow struct {
+	candidate *builder
+	timeout   time.Duration
+	compare   func(live, candidate any) string
+	report    func(ctx context.Context, report ShadowReport)
+}
+
+// shadowSpec is a builder created with Shadow
+type shadowSpec struct {
+	live, candidate any
+	report          func(ctx context.Context, report ShadowReport)
+	opts            []ShadowOption
+}
+
+// Shadow creates a builder that builds its outputs with live and runs candidate in its shadow, the returned value can be
+// passed to DataBuilder.AddBuilders, Configure and Plan.Replace. Options passed to Configure apply to the live builder,
+// except for WithQualifiedInput, inputs are qualified on both the live builder and the candidate.
+//
+// The candidate is called with the inputs of the live builder once the live builder is done, it runs in the background
+// with a timeout of its own and its outputs are never used by the plan. The outputs of both builders are then compared
+// and report is called from the background with the outcome of every comparison, the run of the plan does not wait for it.
+// The candidate should build the same outputs as the live builder and can only depend on inputs of the live builder.
+// When the live builder is created with Each the candidate is called once all elements are built, with the whole collection
+//
+//	err := b.AddBuilders(Shadow(Pricing, NewPricing, func(ctx context.Context, r ShadowReport) {
+//		if r.Diff != "" {
+//			log.Printf("pricing mismatch: %s", r.Diff)
+//		}
+//	}, WithShadowTimeout(200*time.Millisecond)))
+func Shadow(live, candidate any, report func(ctx context.Context, report ShadowReport), opts ...ShadowOption) any {
+	return &shadowSpec{live: live, candidate: candidate, report: report, opts: opts}
+}
+
+// builder creates the live builder with the candidate attached to it
+func (s *shadowSpec) builder() (*builder, error) {
+	if s.report == nil {
+		return nil, fmt.Errorf("%w: shadow needs a report function", ErrInvalidBuilder)
+	}
+	live, err := getBuilder(s.live)
+	if err != nil {
+		return nil, err
+	}
+	candidate, err := getBuilder(s.candidate)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(live.Out, candidate.Out) {
+		return nil, fmt.Errorf("%w: candidate %s should build %v", ErrInvalidBuilder, candidate.Name, live.Out)
+	}
+	inputs := newStringSet(live.In...)
+	inputs.Insert(live.Optional...)
+	if !inputs.IsSuperset(newStringSet(candidate.In...)) || !inputs.IsSuperset(newStringSet(candidate.Optional...)) {
+		return nil, fmt.Errorf("%w: candidate %s should only depend on inputs of %s", ErrInvalidBuilder, candidate.Name, live.Name)
+	}
+	sh := &shadow{
+		candidate: candidate,
+		timeout:   defaultShadowTimeout,
+		compare:   diff,
+		report:    s.report,
+	}
+	for _, opt := range s.opts {
+		if opt != nil {
+			opt(sh)
+		}
+	}
+	if sh.timeout <= 0 || sh.compare == nil {
+		return nil, ErrInvalidOption
+	}
+	live.shadow = sh
+	return live, nil
+}
+
+// diff compares values with cmp.Diff, unexported fields included
+func diff(live, candidate any) string {
+	return cmp.Diff(live, candidate, cmp.Exporter(func(reflect.Type) bool { return true }))
+}
+
+// run calls the candidate with the inputs of the live builder and reports how its outputs compare,
+// it is started once the live builder is done and runs until the candidate returns or times out
+func (s *shadow) run(ctx context.Context, w work, report ShadowReport) {
+	ctx = context.WithoutCancel(ctx)
+	span, ctx := tracing.NewInternalSpan(ctx, s.candidate.Name)
+	defer span.End()
+	span.SetTag("shadow", w.builder.Name)
+	report.Candidate = s.candidate.Name
+
+	start := time.Now()
+	args, ok := buildArgs(s.candidate.params, w.dataMap)
+	if ok {
+		var bErr *BuilderError
+		report.CandidateOutputs, bErr = callBuilder(ctx, work{builder: s.candidate, timeout: s.timeout}, args)
+		if bErr != nil {
+			report.CandidateOutputs = nil
+			report.CandidateErr = span.SetError(bErr)
+		}
+	} else {
+		report.CandidateErr = span.SetError(newBuilderError(s.candidate, ErrWTF, nil, nil))
+	}
+	report.CandidateDuration = time.Since(start)
+	report.Diff = s.diff(w.builder.Out, report)
+	if report.Diff != "" {
+		span.SetTag("mismatch", true)
+	}
+
+	defer func() {
+		// a panic in the report function should not crash the program, the plan is not waiting for it
+		if r := recover(); r != nil {
+			span.SetError(fmt.Errorf("%w: shadow report: %v", ErrBuilderPanic, r))
+		}
+	}()
+	s.report(ctx, report)
+}
+
+// diff describes the differences between the outputs of the live builder and the candidate
+func (s *shadow) diff(outs []string, report ShadowReport) (d string) {
+	defer func() {
+		if r := recover(); r != nil {
+			d = fmt.Sprintf("comparer panicked: %v", r)
+		}
+	}()
+	switch {
+	case report.LiveErr != nil && report.CandidateErr != nil:
+		return ""
+	case report.LiveErr != nil || report.CandidateErr != nil:
+		return fmt.Sprintf("live error: %v, candidate error: %v", report.LiveErr, report.CandidateErr)
+	}
+	var b strings.Builder
+	for i, out := range outs {
+		if diff := s.compare(report.Live[i], report.CandidateOutputs[i]); diff != "" {
+			fmt.Fprintf(&b, "%s:\n%s", out, diff)
+		}
+	}
+	return b.String()
+}
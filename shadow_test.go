@@ -0,0 +1,163 @@
+package databuilder
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestShadow(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	reports := make(chan ShadowReport, 1)
+	report := func(_ context.Context, r ShadowReport) {
+		reports <- r
+	}
+	candidate := func(_ context.Context, s TestStruct1) (TestStruct2, error) {
+		if s.Value == "same" {
+			return TestStruct2{Value: "same"}, nil
+		}
+		return TestStruct2{Value: "candidate"}, nil
+	}
+	live := func(s TestStruct1) TestStruct2 {
+		return TestStruct2{Value: s.Value}
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Shadow(Configure(live, WithName("live")), Configure(candidate, WithName("candidate")), report), func(s TestStruct2) TestStruct3 {
+		return TestStruct3{Value: s.Value}
+	})
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestStruct1{Value: "live"})
+	assert.NoError(t, err)
+	assert.Equal(t, "live", MustGet[TestStruct3](result).Value, "the output of the candidate should never be used")
+	r := <-reports
+	assert.Equal(t, "live", r.Builder)
+	assert.Equal(t, "candidate", r.Candidate)
+	assert.Equal(t, []any{TestStruct2{Value: "live"}}, r.Live)
+	assert.Equal(t, []any{TestStruct2{Value: "candidate"}}, r.CandidateOutputs)
+	assert.Contains(t, r.Diff, "TestStruct2")
+	assert.Contains(t, r.Diff, `"candidate"`)
+
+	_, err = executionPlan.Run(context.Background(), TestStruct1{Value: "same"})
+	assert.NoError(t, err)
+	r = <-reports
+	assert.Empty(t, r.Diff, "the same outputs should not be reported as a mismatch")
+	assert.NoError(t, r.CandidateErr)
+}
+
+func TestShadowFailures(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	reports := make(chan ShadowReport, 1)
+	report := func(_ context.Context, r ShadowReport) {
+		reports <- r
+	}
+	slow := func(ctx context.Context, _ TestStruct1) (TestStruct2, error) {
+		<-ctx.Done()
+		return TestStruct2{}, ctx.Err()
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Shadow(DBTestFunc, slow, report, WithShadowTimeout(10*time.Millisecond)))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = executionPlan.Run(ctx, TestStruct1{Value: "a_b"})
+	cancel()
+	assert.NoError(t, err)
+	r := <-reports
+	assert.ErrorIs(t, r.CandidateErr, context.DeadlineExceeded, "the candidate should run with its own timeout")
+	assert.Nil(t, r.CandidateOutputs)
+	assert.Contains(t, r.Diff, "candidate error")
+
+	d = testNew(t)
+	err = d.AddBuilders(Configure(Shadow(DBTestFuncErr, DBTestFunc, report, WithComparer(func(live, candidate any) string {
+		return "never called"
+	})), WithDefault(TestStruct2{Value: "default"})))
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestStruct1{})
+	assert.NoError(t, err)
+	assert.Equal(t, "default", MustGet[TestStruct2](result).Value, "options should apply to the live builder")
+	r = <-reports
+	assert.Error(t, r.LiveErr, "the live error should be reported instead of the fallback")
+	assert.Nil(t, r.Live)
+	assert.Contains(t, r.Diff, "live error")
+
+	d = testNew(t)
+	err = d.AddBuilders(Shadow(DBTestFunc, DBTestFunc, report, WithComparer(func(live, candidate any) string {
+		return "always different"
+	})))
+	assert.NoError(t, err)
+	executionPlan, err = d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.NoError(t, err)
+	r = <-reports
+	assert.Contains(t, r.Diff, "always different")
+}
+
+func TestShadowEach(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	reports := make(chan ShadowReport, 1)
+	report := func(_ context.Context, r ShadowReport) {
+		reports <- r
+	}
+	live := func(_ context.Context, item string) (TestStruct1, error) {
+		return TestStruct1{Value: strings.ToUpper(item)}, nil
+	}
+	candidate := func(_ context.Context, item string) (TestStruct1, error) {
+		if item == "b" {
+			return TestStruct1{Value: "candidate"}, nil
+		}
+		return TestStruct1{Value: strings.ToUpper(item)}, nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Shadow(Each[TestItems, TestDetails](live), Each[TestItems, TestDetails](candidate), report))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestItems{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestItems{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, TestDetails{{"A"}, {"B"}, {"C"}}, MustGet[TestDetails](result))
+	r := <-reports
+	assert.Equal(t, []any{TestDetails{{"A"}, {"B"}, {"C"}}}, r.Live, "the candidate should be compared with the whole collection")
+	assert.Equal(t, []any{TestDetails{{"A"}, {"candidate"}, {"C"}}}, r.CandidateOutputs)
+	assert.Contains(t, r.Diff, `"candidate"`)
+
+	_, err = executionPlan.Run(context.Background(), TestItems{"a"})
+	assert.NoError(t, err)
+	r = <-reports
+	assert.Empty(t, r.Diff)
+}
+
+func TestShadowValidation(t *testing.T) {
+	report := func(context.Context, ShadowReport) {}
+	assert.ErrorIs(t, IsValidBuilder(Shadow(DBTestFunc, DBTestFunc5, nil)), ErrInvalidBuilder)
+	assert.ErrorIs(t, IsValidBuilder(Shadow(DBTestFunc, DBTestFunc4, report)), ErrInvalidBuilder, "the candidate should build the same outputs")
+	assert.ErrorIs(t, IsValidBuilder(Shadow(DBTestFunc, func(TestStruct1, TestStruct3) TestStruct2 {
+		return TestStruct2{}
+	}, report)), ErrInvalidBuilder, "the candidate should not add inputs")
+	assert.ErrorIs(t, IsValidBuilder(Shadow(DBTestFunc, DBTestFunc5, report, WithShadowTimeout(0))), ErrInvalidOption)
+	assert.NoError(t, IsValidBuilder(Shadow(DBTestFunc, DBTestFunc5, report)))
+
+	d := testNew(t)
+	err := d.AddBuilders(Configure(Shadow(DBTestFunc, DBTestFunc5, report), WithQualifiedInput(TestStruct1{}, "x")))
+	assert.ErrorIs(t, err, ErrInvalidOption, "the candidate would not be qualified")
+	err = d.AddBuilders(DBTestFunc, Shadow(DBTestFunc, DBTestFunc5, report))
+	assert.ErrorIs(t, err, ErrBuilderNameConflict, "the shadow should not be dropped")
+	err = d.AddBuilders(Shadow(DBTestFunc, DBTestFunc5, report))
+	assert.ErrorIs(t, err, ErrBuilderNameConflict, "shadows can not be told apart")
+
+	d = testNew(t)
+	err = d.AddBuilders(Shadow(Configure(DBTestFunc, WithQualifiedInput(TestStruct1{}, "x")), Configure(DBTestFunc5, WithQualifiedInput(TestStruct1{}, "x")), report))
+	assert.NoError(t, err)
+}
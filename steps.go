@@ -0,0 +1,117 @@
+package databuilder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+)
+
+// Step is a builder whose data inputs and outputs are already resolved to the names data is identified with,
+// steps are emitted by the databuilder-gen command so plans can be created and run without reflection.
+// Steps are resolved, scheduled, traced and reported the same way as builders added with DataBuilder.AddBuilders
+type Step struct {
+	// Func is the builder function, it is used to name the step and to check the options of the step
+	Func any
+	// Name overrides the name of the step, the name of Func is used when empty
+	Name string
+	// Inputs are the data inputs of the builder function after context.Context, in the order of its parameters
+	Inputs []StepInput
+	// Out are the names of the data outputs of the builder function, in the order they are returned
+	Out []string
+	// Call calls the builder function with one argument per input and returns its data outputs.
+	// Optional inputs that are absent are passed as a value that is not of the type of the input
+	Call func(ctx context.Context, args []any) ([]any, error)
+	// Options configure how the step is executed
+	Options []BuilderOption
+}
+
+// StepInput is a data input of a Step
+type StepInput struct {
+	// Name is the name the data is identified with
+	Name string
+	// Optional is true for inputs declared as Optional
+	Optional bool
+}
+
+// absentInput is passed to steps for optional inputs that are absent
+type absentInput struct{}
+
+func (absentInput) optionalOf() reflect.Type {
+	return nil
+}
+
+func (absentInput) with(data any) any {
+	return data
+}
+
+// NewPlan creates a plan from steps, the plan only contains the steps needed to produce targets,
+// or all steps when targets is nil. initialData are the names of the data the plan is run with
+func NewPlan(steps []Step, initialData, targets []string, opts ...Option) (Plan, error) {
+	d := New(opts...).(*db)
+	d.builders = make(map[string]*builder)
+	d.outSet = newStringSet()
+	for _, s := range steps {
+		b, err := s.builder()
+		if err != nil {
+			return nil, err
+		}
+		if err := d.insert(b); err != nil {
+			return nil, err
+		}
+	}
+	return d.compile(targets, initialData, nil)
+}
+
+func (s Step) builder() (*builder, error) {
+	if s.Func == nil || reflect.TypeOf(s.Func).Kind() != reflect.Func || s.Call == nil || len(s.Out) == 0 {
+		return nil, ErrInvalidBuilder
+	}
+	fnValue := reflect.ValueOf(s.Func)
+	if fnValue.IsNil() {
+		return nil, ErrInvalidBuilder
+	}
+	b := &builder{
+		fnValue: fnValue,
+		Name:    s.Name,
+		named:   s.Name != "",
+		Out:     slices.Clone(s.Out),
+		call:    s.Call,
+	}
+	if outs := outputTypes(fnValue.Type()); len(outs) == len(s.Out) {
+		// the types of the outputs are used to bind interface inputs and to check fallbacks
+		b.outTypes = outs
+	}
+	if b.Name == "" {
+		b.Name = runtime.FuncForPC(fnValue.Pointer()).Name()
+	}
+	outputs := newStringSet()
+	for _, out := range s.Out {
+		if outputs.Has(out) {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateOutput, b.Name)
+		}
+		outputs.Insert(out)
+	}
+	for _, in := range s.Inputs {
+		if outputs.Has(in.Name) {
+			return nil, fmt.Errorf("%w: %s", ErrSameInputAsOutput, b.Name)
+		}
+		if in.Optional {
+			b.params = append(b.params, param{name: in.Name, optional: absentInput{}})
+			continue
+		}
+		b.params = append(b.params, param{name: in.Name})
+	}
+	b.setInputs()
+	for _, opt := range s.Options {
+		if opt == nil {
+			continue
+		}
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+	b.opts = s.Options
+	return b, nil
+}
@@ -0,0 +1,62 @@
+package databuilder
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlan(t *testing.T) {
+	ts1 := getStructName(reflect.TypeFor[TestStruct1]())
+	ts2 := getStructName(reflect.TypeFor[TestStruct2]())
+	ts3 := getStructName(reflect.TypeFor[TestStruct3]())
+	steps := []Step{
+		{
+			Func:   DBTestFunc,
+			Inputs: []StepInput{{Name: ts1}},
+			Out:    []string{ts2},
+			Call: func(ctx context.Context, args []any) ([]any, error) {
+				o0, err := DBTestFunc(ctx, args[0].(TestStruct1))
+				return []any{o0}, err
+			},
+			Options: []BuilderOption{WithDefault(TestStruct2{})},
+		},
+		{
+			Func:   DBTestFunc4,
+			Name:   "optional",
+			Inputs: []StepInput{{Name: ts1}, {Name: ts2 + "@missing", Optional: true}},
+			Out:    []string{ts3},
+			Call: func(_ context.Context, args []any) ([]any, error) {
+				_, ok := args[1].(TestStruct2)
+				return []any{TestStruct3{Value: args[0].(TestStruct1).Value + strconv.FormatBool(ok)}}, nil
+			},
+		},
+	}
+	executionPlan, err := NewPlan(steps, []string{ts1}, nil)
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a_b", MustGet[TestStruct2](result).Value)
+	assert.Equal(t, "a-bfalse", MustGet[TestStruct3](result).Value, "absent optional inputs should not be of the input type")
+
+	executionPlan, err = NewPlan(steps, []string{ts1}, []string{ts2})
+	assert.NoError(t, err)
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a-b"})
+	assert.NoError(t, err)
+	_, ok := Get[TestStruct3](result)
+	assert.False(t, ok, "steps not needed for the targets should not run")
+
+	other := steps[0]
+	other.Name = "other"
+	_, err = NewPlan(append(steps, other), []string{ts1}, nil)
+	assert.ErrorIs(t, err, ErrMultipleBuilderSameOutput)
+	_, err = NewPlan([]Step{{Func: DBTestFunc, Out: []string{ts2}}}, nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidBuilder)
+	_, err = NewPlan([]Step{{Func: DBTestFunc, Inputs: []StepInput{{Name: ts2}}, Out: []string{ts2}, Call: steps[0].Call}}, nil, nil)
+	assert.ErrorIs(t, err, ErrSameInputAsOutput)
+	_, err = NewPlan(steps[:1], nil, nil)
+	assert.ErrorIs(t, err, ErrCouldNotResolveDependency)
+}
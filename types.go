@@ -3,6 +3,8 @@ package databuilder
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 )
 
 var (
@@ -11,15 +13,19 @@ var (
 	// ErrInvalidBuilderKind is returned when the builder is not a function
 	ErrInvalidBuilderKind = errors.New("invalid builder, should only be a function")
 	// ErrInvalidBuilderNumInput is returned when the builder does not have 1 input
-	ErrInvalidBuilderNumOutput = errors.New("invalid builder, should always return two values")
-	// ErrInvalidBuilderFirstOutput is returned when the builder does not return a struct as first output
-	ErrInvalidBuilderFirstOutput = errors.New("invalid builder, first return type should be a struct")
-	// ErrInvalidBuilderSecondOutput is returned when the builder does not return an error as second output
-	ErrInvalidBuilderSecondOutput = errors.New("invalid builder, second return type should be error")
-	// ErrInvalidBuilderMissingContext is returned when the builder does not have a context as first input
+	ErrInvalidBuilderNumOutput = errors.New("invalid builder, should return at least one value besides the error")
+	// ErrInvalidBuilderFirstOutput is returned when the builder does not return data before the error
+	ErrInvalidBuilderFirstOutput = errors.New("invalid builder, return types before error should be named types or pointers to named types")
+	// ErrInvalidBuilderSecondOutput is returned when the last output of the builder is neither data nor an error
+	ErrInvalidBuilderSecondOutput = errors.New("invalid builder, last return type should be data or error")
+	// ErrDuplicateOutput is returned when the builder returns the same type more than once
+	ErrDuplicateOutput = errors.New("invalid builder, return types should all be different")
+	// ErrInvalidBuilderMissingContext is no longer returned, context.Context is an optional first input of builders
+	//
+	// Deprecated: builders do not need to take a context.Context
 	ErrInvalidBuilderMissingContext = errors.New("invalid builder, missing context")
-	// ErrInvalidBuilderInput is returned when the builder does not have a struct as input
-	ErrInvalidBuilderInput = errors.New("invalid builder, input should be a struct")
+	// ErrInvalidBuilderInput is returned when the builder does not have data as input
+	ErrInvalidBuilderInput = errors.New("invalid builder, input should be a named type, a pointer to a named type or an interface")
 	// ErrInvalidBuilderOutput is returned when the builder does not have a struct as output
 	ErrMultipleBuilderSameOutput = errors.New("invalid, multiple builders CAN NOT produce the same output")
 	// ErrSameInputAsOutput is returned when the builder has the same input and output
@@ -30,26 +36,121 @@ var (
 	ErrMultipleInitialData = errors.New("initial data provided twice")
 	// ErrInitialDataMissing is returned when the initial data is not provided
 	ErrInitialDataMissing = errors.New("need complile time defined initial data to run")
+	// ErrAmbiguousDependency is returned when more than one builder output or initial data implements an interface input,
+	// or when more than one alternative builder can build the same output with the same priority
+	ErrAmbiguousDependency = errors.New("dependency can be satisfied in more than one way")
+	// ErrNoProducer is returned when no builder produces a target requested from CompileFor
+	ErrNoProducer = errors.New("no builder produces the requested target")
+	// ErrDependencyFailed is returned when a builder is skipped because one of its inputs could not be built
+	ErrDependencyFailed = errors.New("dependency failed")
+	// ErrBuilderPanic is returned when a builder panics
+	ErrBuilderPanic = errors.New("panic in builder")
+	// ErrBuilderNameConflict is returned when a different builder is added with a name that is already in use
+	ErrBuilderNameConflict = errors.New("a different builder with the same name already exists")
+	// ErrNilOutput is returned when a builder returns a nil pointer without an error
+	ErrNilOutput = errors.New("builder returned a nil pointer without an error")
+	// ErrNoVariant is returned when none of the variants of a builder created with OneOf is chosen
+	ErrNoVariant = errors.New("no variant chosen")
 )
 
+// BuilderError is returned for every builder that fails, it wraps the error returned by the builder
+// so sentinel checks like errors.Is(err, context.Canceled) keep working
+type BuilderError struct {
+	// Builder is the name of the builder that failed
+	Builder string
+	// Outputs are the names of the outputs the builder was supposed to build
+	Outputs []string
+	// Inputs are the names of the inputs of the builder
+	Inputs []string
+	// Err is the error returned by the builder, ErrBuilderPanic if the builder panicked
+	Err error
+	// PanicValue is the value the builder panicked with, nil if the builder did not panic
+	PanicValue any
+	// Stack is the stack trace of the panic, nil if the builder did not panic
+	Stack []byte
+}
+
+func (e *BuilderError) Error() string {
+	if e.PanicValue != nil {
+		return fmt.Sprintf("builder %s failed to build %s: %s: %v", e.Builder, strings.Join(e.Outputs, ", "), e.Err, e.PanicValue)
+	}
+	return fmt.Sprintf("builder %s failed to build %s: %s", e.Builder, strings.Join(e.Outputs, ", "), e.Err)
+}
+
+func (e *BuilderError) Unwrap() error {
+	return e.Err
+}
+
+// DependencyFailedError is returned for every builder that is skipped because a builder it depends on failed
+// it can be matched with errors.Is(err, ErrDependencyFailed)
+type DependencyFailedError struct {
+	// Builder is the name of the builder that was skipped
+	Builder string
+	// Input is the input of the builder that could not be built
+	Input string
+	// FailedBuilder is the name of the builder whose failure caused the input to be missing
+	FailedBuilder string
+}
+
+func (e *DependencyFailedError) Error() string {
+	return fmt.Sprintf("%s: builder %s skipped, input %s missing as builder %s failed", ErrDependencyFailed, e.Builder, e.Input, e.FailedBuilder)
+}
+
+func (e *DependencyFailedError) Unwrap() error {
+	return ErrDependencyFailed
+}
+
 // DataBuilder is the interface for DataBuilder
 type DataBuilder interface {
 	// AddBuilders adds the builders to the DataBuilder. The builders are added to the DataBuilder
+	// A builder can be wrapped with Configure to set options on how it is executed
+	// Builders are identified by the name of their function, a builder that is added again is ignored. Closures and method values
+	// share the name of the function that created them, adding more than one of them fails with ErrBuilderNameConflict unless they are named with AddNamedBuilder or WithName
 	AddBuilders(fn ...any) error
+	// AddNamedBuilder adds the builder under the given name, the name is used in tracing spans, errors, the dependency graph and
+	// to replace the builder with Plan.Replace. Adding a builder with a name that is already in use fails with ErrBuilderNameConflict
+	AddNamedBuilder(name string, fn any) error
 	// Compile compiles the builders and returns a plan that can be used to run the builders
 	// The initial data is used to resolve the dependencies of the builders. The initial data should be a struct that contains the fields that are used as input for the builders when this Plan is executed.
 	Compile(initialData ...any) (Plan, error)
+	// CompileFor compiles a plan that only contains the builders needed to produce the targets
+	// The targets should be structs of the types that are required from the plan, builders that do not contribute to any of the targets are not part of the plan. The initial data is used the same way as in Compile.
+	CompileFor(targets []any, initialData ...any) (Plan, error)
 }
 
 // Plan is the interface that wraps execution of Plans created by DataBuilder.Compile method.
 type Plan interface {
-	// Replace replaces the builder function used in compile with a different function. The builder function should be the same as the one used in AddBuilders
+	// Replace replaces the builder function used in compile with a different function. from is either the builder function used in AddBuilders
+	// or the name of the builder, builders added with AddNamedBuilder or WithName should be replaced by their name
 	Replace(ctx context.Context, from, to any) error
 	// Run runs the builders in the plan. The initial data is used to resolve the dependencies of the builders. The initial data should be a struct that contains the fields that are used as input for the builders when this Plan is executed.
+	// RunOption values can be passed along with the initial data to configure the run.
 	Run(ctx context.Context, initValues ...any) (Result, error)
-	// RunParallel runs the builders in the plan in parallel. The initial data is used to resolve the dependencies of the builders. The initial data should be a struct that contains the fields that are used as input for the builders when this Plan is executed.
+	// RunParallel runs the builders in the plan in parallel using at most count workers, each builder is started as soon as all of its inputs have been built. The initial data is used to resolve the dependencies of the builders. The initial data should be a struct that contains the fields that are used as input for the builders when this Plan is executed.
 	RunParallel(ctx context.Context, count uint, initValues ...any) (Result, error)
 }
 
 // Result is the result of the Plan.Run method
 type Result map[string]any
+
+// Warnings is added to the Result when builders failed and their fallback was used instead,
+// it holds the errors of those builders as *BuilderError. Builders that fell back are not
+// reported as errors of the plan
+//
+//	if w, ok := Get[Warnings](result); ok {
+//		log.Println("degraded response", w.Errors)
+//	}
+type Warnings struct {
+	Errors []error
+}
+
+// Variants is added to the Result when builders created with OneOf ran, it holds the name
+// of the variant chosen for every one of them
+//
+//	if v, ok := Get[Variants](result); ok {
+//		log.Println("ranking variant", v.Chosen["ranking-experiment"])
+//	}
+type Variants struct {
+	// Chosen is the mapping between the name of the builder and the name of the variant chosen
+	Chosen map[string]string
+}
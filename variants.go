@@ -0,0 +1,133 @@
+package databuilder
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+)
+
+// Selector reports whether a variant is chosen for a run, data holds the initial data and the data built so far
+type Selector func(ctx context.Context, data Result) bool
+
+// Variant is a candidate builder of OneOf
+type Variant struct {
+	// Builder is the builder function, it can be wrapped with Configure to name the variant with WithName and to qualify
+	// its inputs with WithQualifiedInput. Other options fail with ErrInvalidOption, they apply to the builder as a whole
+	Builder any
+	// Select reports whether the variant is chosen, a variant without Select is always chosen
+	Select Selector
+}
+
+// OneOf creates a builder that chooses one of the variants every time a plan runs it, the returned value can be
+// passed to DataBuilder.AddBuilders, Configure and Plan.Replace. The variants should build the same outputs, the builder
+// waits for the inputs of every variant and is configured as a whole with the options passed to Configure.
+//
+// Variants are checked in order and the first one that is chosen builds the outputs, the builder fails with
+// ErrNoVariant when none is chosen. The name of the chosen variant is recorded on the tracing span of the builder
+// and in the Variants of the Result
+//
+//	ranking := OneOf(
+//		Variant{Builder: Configure(NewRanking, WithName("new-ranking")), Select: Percentage(10, "ranking", UserKey)},
+//		Variant{Builder: Configure(Ranking, WithName("ranking"))},
+//	)
+//	err := b.AddBuilders(Configure(ranking, WithName("ranking-experiment")))
+func OneOf(variants ...Variant) any {
+	return &variantSpec{variants: variants}
+}
+
+// variantSpec is a builder created with OneOf
+type variantSpec struct {
+	variants []Variant
+}
+
+type variant struct {
+	*builder
+	selector Selector
+}
+
+// Percentage chooses the variant for percent of the keys returned by key, a key is always given the same answer
+// so runs for the same user or request stay on the same variant. Keys are hashed with the salt, experiments should
+// use salts of their own so their keys are split independently of each other. Variants of the same OneOf should
+// share the salt, keys are then hashed to the same bucket for every variant and later variants should be given a
+// higher percentage to split the keys between them:
+//
+//	Variant{Builder: A, Select: Percentage(10, "ranking", key)} // 10% of the keys
+//	Variant{Builder: B, Select: Percentage(30, "ranking", key)} // the next 20% of the keys
+//	Variant{Builder: C}                                         // the remaining 70% of the keys
+func Percentage(percent float64, salt string, key func(ctx context.Context, data Result) string) Selector {
+	return func(ctx context.Context, data Result) bool {
+		h := fnv.New32a()
+		h.Write([]byte(salt))
+		// separate the salt from the key, so that "ab"+"c" and "a"+"bc" are hashed differently
+		h.Write([]byte{0})
+		h.Write([]byte(key(ctx, data)))
+		return float64(h.Sum32()%10000) < percent*100
+	}
+}
+
+// builder creates the builder choosing between the variants, its inputs are the inputs of all variants
+func (s *variantSpec) builder() (*builder, error) {
+	if len(s.variants) == 0 {
+		return nil, fmt.Errorf("%w: no variant", ErrInvalidBuilder)
+	}
+	b := &builder{}
+	names := make([]string, 0, len(s.variants))
+	params := make(map[string]int)
+	for i, v := range s.variants {
+		vb, err := getBuilder(v.Builder)
+		if err != nil {
+			return nil, err
+		}
+		if vb.timeout != 0 || vb.retry != nil || vb.fallback != nil || vb.alternative {
+			// plans run the variants with the options of the builder they are part of
+			return nil, fmt.Errorf("%w: variant %s can only be named, configure the builder created with OneOf instead", ErrInvalidOption, vb.Name)
+		}
+		if len(vb.variants) > 0 || vb.shadow != nil {
+			return nil, fmt.Errorf("%w: variant %s can not be created with OneOf or Shadow", ErrInvalidBuilder, vb.Name)
+		}
+		if i == 0 {
+			b.Out, b.outTypes = vb.Out, vb.outTypes
+		} else if !slices.Equal(vb.Out, b.Out) {
+			return nil, fmt.Errorf("%w: variant %s should build %v", ErrInvalidBuilder, vb.Name, b.Out)
+		}
+		for _, p := range vb.inputs() {
+			j, ok := params[p.name]
+			if !ok {
+				params[p.name] = len(b.params)
+				b.params = append(b.params, param{name: p.name, optional: p.optional, zeroIfAbsent: p.zeroIfAbsent, iface: p.iface})
+				continue
+			}
+			if !p.isOptional() {
+				// required by one of the variants
+				b.params[j].optional, b.params[j].zeroIfAbsent = nil, false
+			}
+		}
+		b.variants = append(b.variants, variant{builder: vb, selector: v.Select})
+		names = append(names, vb.Name)
+	}
+	b.Name = strings.Join(names, "|")
+	b.setInputs()
+	b.call = func(context.Context, []any) ([]any, error) {
+		// plans call the chosen variant
+		return nil, ErrWTF
+	}
+	return b, nil
+}
+
+// choose returns the first variant that is chosen for the run
+func (b *builder) choose(ctx context.Context, data Result) (v *builder, err error) {
+	defer func() {
+		// recover from panic and set error
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: selector: %v", ErrBuilderPanic, r)
+		}
+	}()
+	for _, v := range b.variants {
+		if v.selector == nil || v.selector(ctx, data) {
+			return v.builder, nil
+		}
+	}
+	return nil, ErrNoVariant
+}
@@ -0,0 +1,109 @@
+package databuilder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestOneOf(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	type beta struct{}
+	legacy := func(s TestStruct1) TestStruct2 {
+		return TestStruct2{Value: "legacy:" + s.Value}
+	}
+	candidate := func(_ context.Context, s TestStruct1, o Optional[TestStruct3]) (TestStruct2, error) {
+		return TestStruct2{Value: "candidate:" + s.Value + o.Value.Value}, nil
+	}
+	d := testNew(t)
+	err := d.AddBuilders(Configure(OneOf(
+		Variant{Builder: Configure(candidate, WithName("candidate")), Select: func(ctx context.Context, _ Result) bool {
+			return ctx.Value(beta{}) != nil
+		}},
+		Variant{Builder: Configure(legacy, WithName("legacy"))},
+	), WithName("experiment")))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+
+	result, err := executionPlan.RunParallel(context.Background(), 2, TestStruct1{Value: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy:a", MustGet[TestStruct2](result).Value)
+	assert.Equal(t, map[string]string{"experiment": "legacy"}, MustGet[Variants](result).Chosen)
+
+	ctx := context.WithValue(context.Background(), beta{}, true)
+	result, err = executionPlan.Run(ctx, TestStruct1{Value: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "candidate:a", MustGet[TestStruct2](result).Value, "the variant should be chosen for every run")
+	assert.Equal(t, map[string]string{"experiment": "candidate"}, MustGet[Variants](result).Chosen)
+
+	result, err = executionPlan.Run(context.Background(), TestStruct1{Value: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy:a", MustGet[TestStruct2](result).Value, "choosing a variant should not change the plan")
+}
+
+func TestOneOfErrors(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	never := func(context.Context, Result) bool { return false }
+	d := testNew(t)
+	err := d.AddBuilders(OneOf(Variant{Builder: DBTestFunc, Select: never}))
+	assert.NoError(t, err)
+	executionPlan, err := d.Compile(TestStruct1{})
+	assert.NoError(t, err)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, ErrNoVariant)
+
+	err = executionPlan.Replace(context.Background(), DBTestFunc, OneOf(Variant{Builder: DBTestFunc, Select: func(context.Context, Result) bool {
+		panic("selector panic")
+	}}))
+	assert.NoError(t, err)
+	_, err = executionPlan.Run(context.Background(), TestStruct1{})
+	assert.ErrorIs(t, err, ErrBuilderPanic)
+
+	err = executionPlan.Replace(context.Background(), DBTestFunc, Configure(OneOf(Variant{Builder: DBTestFunc, Select: never}), WithDefault(TestStruct2{Value: "default"})))
+	assert.NoError(t, err)
+	result, err := executionPlan.Run(context.Background(), TestStruct1{})
+	assert.NoError(t, err)
+	assert.Equal(t, "default", MustGet[TestStruct2](result).Value, "the fallback should be used when no variant is chosen")
+	assert.ErrorIs(t, MustGet[Warnings](result).Errors[0], ErrNoVariant)
+
+	assert.ErrorIs(t, IsValidBuilder(OneOf()), ErrInvalidBuilder)
+	assert.ErrorIs(t, IsValidBuilder(OneOf(Variant{Builder: DBTestFunc}, Variant{Builder: DBTestFunc2})), ErrInvalidBuilder, "variants should build the same outputs")
+	assert.ErrorIs(t, IsValidBuilder(OneOf(Variant{Builder: DBTestFuncInvalid5})), ErrSameInputAsOutput)
+	assert.ErrorIs(t, IsValidBuilder(OneOf(Variant{Builder: Configure(DBTestFunc, WithTimeout(time.Millisecond))})), ErrInvalidOption, "options of variants would be ignored")
+	assert.ErrorIs(t, IsValidBuilder(OneOf(Variant{Builder: Configure(DBTestFunc, WithDefault(TestStruct2{}))})), ErrInvalidOption)
+	assert.ErrorIs(t, IsValidBuilder(OneOf(Variant{Builder: OneOf(Variant{Builder: DBTestFunc})})), ErrInvalidBuilder)
+	assert.ErrorIs(t, testNew(t).AddBuilders(DBTestFunc, OneOf(Variant{Builder: DBTestFunc})), ErrBuilderNameConflict, "the variants should not be dropped")
+	assert.ErrorIs(t, testNew(t).AddBuilders(Configure(OneOf(Variant{Builder: DBTestFunc}), WithQualifiedInput(TestStruct1{}, "x"))), ErrInvalidOption, "inputs are qualified on the variants")
+	assert.NoError(t, testNew(t).AddBuilders(OneOf(Variant{Builder: Configure(DBTestFunc, WithName("a"), WithQualifiedInput(TestStruct1{}, "x"))})))
+}
+
+func TestPercentage(t *testing.T) {
+	key := func(ctx context.Context, data Result) string {
+		return MustGet[TestStruct1](data).Value
+	}
+	name := getStructName(reflect.TypeFor[TestStruct1]())
+	canary := Percentage(20, "canary", key)
+	other := Percentage(20, "other", key)
+	chosen, both := 0, 0
+	for i := 0; i < 1000; i++ {
+		data := Result{name: TestStruct1{Value: fmt.Sprint("user-", i)}}
+		first := canary(context.Background(), data)
+		assert.Equal(t, first, canary(context.Background(), data), "the same key should always be given the same answer")
+		if first {
+			chosen++
+			if other(context.Background(), data) {
+				both++
+			}
+		}
+	}
+	assert.InDelta(t, 200, chosen, 50)
+	assert.InDelta(t, 40, both, 25, "experiments with different salts should split the keys independently")
+	assert.False(t, Percentage(0, "canary", key)(context.Background(), Result{name: TestStruct1{}}))
+	assert.True(t, Percentage(100, "canary", key)(context.Background(), Result{name: TestStruct1{}}))
+}